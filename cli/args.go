@@ -2,9 +2,14 @@ package cli
 
 import (
 	"hund/util"
+	"regexp"
 	"strings"
 )
 
+const EndOfOptions = "--"
+
+var negativeNumberExpression = regexp.MustCompile(`^-[0-9]+(\.[0-9]+)?$`)
+
 type ArgTokenKind int
 
 const (
@@ -14,8 +19,10 @@ const (
 )
 
 type ArgToken struct {
-	value string
-	kind  ArgTokenKind
+	value    string
+	kind     ArgTokenKind
+	attached string
+	hasValue bool
 }
 
 func parseArgToken(arg string) (ArgToken, error) {
@@ -25,6 +32,10 @@ func parseArgToken(arg string) (ArgToken, error) {
 		return token, util.NewError("empty string")
 	}
 
+	if negativeNumberExpression.MatchString(arg) {
+		return ArgToken{value: arg, kind: ValueArg}, nil
+	}
+
 	token.value = strings.TrimLeft(arg, "-")
 	diff := len(arg) - len(token.value)
 
@@ -41,9 +52,26 @@ func parseArgToken(arg string) (ArgToken, error) {
 		token.kind = LongOpt
 	}
 
-	if token.kind == ShortOpt && len(token.value) > 1 {
-		return token, util.NewError("invalid short option %s", arg)
+	if token.kind == LongOpt {
+		token.value, token.attached, token.hasValue = strings.Cut(token.value, "=")
+		if token.value == "" {
+			return token, util.NewError("invalid argument \"%s\"", arg)
+		}
 	}
 
 	return token, nil
 }
+
+func (self ArgToken) shortOptions() []ArgToken {
+	result := []ArgToken{}
+	for i, short := range self.value {
+		token := ArgToken{value: string(short), kind: ShortOpt}
+		rest := self.value[i+len(string(short)):]
+		if rest != "" {
+			token.attached = rest
+			token.hasValue = true
+		}
+		result = append(result, token)
+	}
+	return result
+}
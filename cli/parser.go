@@ -1,10 +1,13 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"hund/logger"
 	"hund/util"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -18,31 +21,79 @@ const (
 )
 
 type Argument struct {
-	name string
-	kind ArgumentKind
+	name         string
+	kind         ArgumentKind
+	valueType    ValueType
+	defaultValue string
+	choices      []string
 }
 
 func (self Argument) isTerminating() bool {
 	return self.kind != SingleArg
 }
 
+func (self Argument) check(value string, baseDir string, static bool) error {
+	var err error
+	if static {
+		err = checkFormat("argument", self.name, self.valueType, value)
+	} else {
+		err = checkType("argument", self.name, self.valueType, value, baseDir)
+	}
+	if err != nil {
+		return err
+	}
+	return checkChoices("argument", self.name, self.choices, value)
+}
+
 type OptionKind int
 
 const (
 	FlagOpt  OptionKind = iota
 	ValueOpt OptionKind = iota
+	ListOpt  OptionKind = iota
+	CountOpt OptionKind = iota
 )
 
 type Option struct {
-	name      string
-	shortname string
-	kind      OptionKind
+	name         string
+	shortname    string
+	kind         OptionKind
+	valueType    ValueType
+	defaultValue string
+	choices      []string
+}
+
+func (self Option) takesValue() bool {
+	return self.kind == ValueOpt || self.kind == ListOpt
 }
 
+func (self Option) isNegatable() bool {
+	return self.kind == FlagOpt && self.defaultValue == "true"
+}
+
+func (self Option) check(value string, baseDir string, static bool) error {
+	var err error
+	if static {
+		err = checkFormat("option", self.name, self.valueType, value)
+	} else {
+		err = checkType("option", self.name, self.valueType, value, baseDir)
+	}
+	if err != nil {
+		return err
+	}
+	return checkChoices("option", self.name, self.choices, value)
+}
+
+const HelpName = "help"
+const HelpShortname = "h"
+
+var ErrHelpRequested = errors.New("help requested")
+
 type CliParser struct {
 	options        []Option
 	arguments      []Argument
 	terminatingArg bool
+	baseDir        string
 }
 
 func (self *CliParser) String() string {
@@ -65,6 +116,10 @@ func NewCliParser() *CliParser {
 	return &CliParser{}
 }
 
+func (self *CliParser) SetBaseDir(baseDir string) {
+	self.baseDir = baseDir
+}
+
 func (self *CliParser) AddArgument(kind ArgumentKind, name string) error {
 	logger.Debugf("adding argument \"%s\" to parser", name)
 	if self.Contains(name) {
@@ -120,12 +175,15 @@ func (self *CliParser) Add(spec string) error {
 		return util.NewError("empty spec provided")
 	}
 
-	argumentExp := regexp.MustCompile(`^(?P<name>[a-zA-Z][a-zA-Z0-9-]*)(?P<type>[\?\*\+])?$`)
+	argumentExp := regexp.MustCompile(`^(?P<name>[a-zA-Z][a-zA-Z0-9-]*)(?P<type>[\?\*\+])?(:(?P<valueType>[a-z]+))?(\[(?P<choices>[^\]]*)\])?(\((?P<default>[^)]*)\))?$`)
 	matches := argumentExp.FindStringSubmatch(spec)
 	if matches != nil {
 		logger.Debugf("detected argument")
 		specName := matches[argumentExp.SubexpIndex("name")]
 		specType := matches[argumentExp.SubexpIndex("type")]
+		specValueType := matches[argumentExp.SubexpIndex("valueType")]
+		specChoices := matches[argumentExp.SubexpIndex("choices")]
+		specDefault := matches[argumentExp.SubexpIndex("default")]
 		var kind ArgumentKind
 		switch specType {
 		case "?":
@@ -137,11 +195,23 @@ func (self *CliParser) Add(spec string) error {
 		default:
 			kind = SingleArg
 		}
-		return self.AddArgument(kind, specName)
+		err := self.AddArgument(kind, specName)
+		if err != nil {
+			return err
+		}
+		err = self.SetType(specName, specValueType)
+		if err != nil {
+			return err
+		}
+		err = self.SetChoices(specName, specChoices)
+		if err != nil {
+			return err
+		}
+		return self.SetDefault(specName, specDefault)
 	}
 	logger.Debugf("spec does not represent argument")
 
-	optionExp := regexp.MustCompile(`^(?P<name>[a-zA-Z][a-zA-Z-]*)(\|(?P<short>[a-zA-Z]))?=(?P<type>value|flag)`)
+	optionExp := regexp.MustCompile(`^(?P<name>[a-zA-Z][a-zA-Z-]*)(\|(?P<short>[a-zA-Z]))?=(?P<type>value|flag|list|count)(:(?P<valueType>[a-z]+))?(\[(?P<choices>[^\]]*)\])?(\((?P<default>[^)]*)\))?$`)
 	matches = optionExp.FindStringSubmatch(spec)
 	if matches == nil {
 		return util.NewError("failed to parse spec \"%s\"", spec)
@@ -150,12 +220,178 @@ func (self *CliParser) Add(spec string) error {
 	optName := matches[optionExp.SubexpIndex("name")]
 	optShort := matches[optionExp.SubexpIndex("short")]
 	optType := matches[optionExp.SubexpIndex("type")]
+	optValueType := matches[optionExp.SubexpIndex("valueType")]
+	optChoices := matches[optionExp.SubexpIndex("choices")]
+	optDefault := matches[optionExp.SubexpIndex("default")]
 
 	kind := FlagOpt
-	if optType == "value" {
+	switch optType {
+	case "value":
 		kind = ValueOpt
+	case "list":
+		kind = ListOpt
+	case "count":
+		kind = CountOpt
+	}
+	err := self.AddOption(kind, optName, optShort)
+	if err != nil {
+		return err
+	}
+	err = self.SetType(optName, optValueType)
+	if err != nil {
+		return err
+	}
+	err = self.SetChoices(optName, optChoices)
+	if err != nil {
+		return err
+	}
+	return self.SetDefault(optName, optDefault)
+}
+
+func (self *CliParser) SetType(name string, typeName string) error {
+	if typeName == "" {
+		return nil
+	}
+
+	valueType, err := parseValueType(typeName)
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("setting type \"%s\" for \"%s\"", valueType, name)
+	for i, argument := range self.arguments {
+		if argument.name == name {
+			self.arguments[i].valueType = valueType
+			return nil
+		}
+	}
+
+	for i, option := range self.options {
+		if option.name != name {
+			continue
+		}
+		if !option.takesValue() {
+			return util.NewError("can't set type for \"%s\", only value and list options can have types", name)
+		}
+		self.options[i].valueType = valueType
+		return nil
+	}
+
+	return util.NewError("can't set type, parser doesn't have \"%s\"", name)
+}
+
+func (self *CliParser) SetChoices(name string, spec string) error {
+	if spec == "" {
+		return nil
+	}
+
+	choices := []string{}
+	for _, choice := range strings.Split(spec, ",") {
+		choice = strings.TrimSpace(choice)
+		if choice == "" {
+			return util.NewError("empty choice in \"%s\" allowed values", name)
+		}
+		choices = append(choices, choice)
+	}
+
+	logger.Debugf("setting choices %v for \"%s\"", choices, name)
+	for i, argument := range self.arguments {
+		if argument.name == name {
+			self.arguments[i].choices = choices
+			return nil
+		}
+	}
+
+	for i, option := range self.options {
+		if option.name != name {
+			continue
+		}
+		if !option.takesValue() {
+			return util.NewError("can't set allowed values for \"%s\", only value and list options can have them", name)
+		}
+		self.options[i].choices = choices
+		return nil
+	}
+
+	return util.NewError("can't set allowed values, parser doesn't have \"%s\"", name)
+}
+
+func (self *CliParser) SetDefault(name string, value string) error {
+	if value == "" {
+		return nil
+	}
+
+	logger.Debugf("setting default \"%s\" for \"%s\"", value, name)
+	for i, argument := range self.arguments {
+		if argument.name != name {
+			continue
+		}
+		if argument.kind != OptionalArg {
+			return util.NewError("can't set default for \"%s\", only optional arguments can have default values", name)
+		}
+		err := checkFormat("argument", name, argument.valueType, value)
+		if err != nil {
+			return err
+		}
+		err = checkChoices("argument", name, argument.choices, value)
+		if err != nil {
+			return err
+		}
+		self.arguments[i].defaultValue = value
+		return nil
+	}
+
+	for i, option := range self.options {
+		if option.name != name {
+			continue
+		}
+		if option.kind == FlagOpt {
+			if value != "true" && value != "false" {
+				return util.NewError("invalid default \"%s\" for flag \"%s\", expected true or false", value, name)
+			}
+			self.options[i].defaultValue = value
+			return nil
+		}
+		if option.kind != ValueOpt {
+			return util.NewError("can't set default for \"%s\", only value options and flags can have default values", name)
+		}
+		err := checkFormat("option", name, option.valueType, value)
+		if err != nil {
+			return err
+		}
+		err = checkChoices("option", name, option.choices, value)
+		if err != nil {
+			return err
+		}
+		self.options[i].defaultValue = value
+		return nil
+	}
+
+	return util.NewError("can't set default, parser doesn't have \"%s\"", name)
+}
+
+func (self *CliParser) Defaults(flagValue string) map[string]string {
+	result := make(map[string]string)
+
+	for _, option := range self.options {
+		if option.isNegatable() {
+			result[option.name] = flagValue
+		}
+		if option.kind == CountOpt {
+			result[option.name] = "0"
+		}
+		if option.kind == ValueOpt && option.defaultValue != "" {
+			result[option.name] = option.defaultValue
+		}
+	}
+
+	for _, argument := range self.arguments {
+		if argument.defaultValue != "" {
+			result[argument.name] = argument.defaultValue
+		}
 	}
-	return self.AddOption(kind, optName, optShort)
+
+	return result
 }
 
 func (self *CliParser) Contains(name string) bool {
@@ -173,6 +409,21 @@ func (self *CliParser) Contains(name string) bool {
 	return false
 }
 
+func (self *CliParser) IsList(name string) bool {
+	for _, argument := range self.arguments {
+		if argument.name == name {
+			return argument.kind == AtLeastOneArg || argument.kind == AnyArg
+		}
+	}
+
+	for _, option := range self.options {
+		if option.name == name {
+			return option.kind == ListOpt
+		}
+	}
+	return false
+}
+
 func (self *CliParser) Names() []string {
 	result := []string{}
 
@@ -188,23 +439,52 @@ func (self *CliParser) Names() []string {
 }
 
 func (self *CliParser) Parse(args []string, writer CliWriter) ([]string, error) {
+	return self.parse(args, writer, nil, false)
+}
+
+func (self *CliParser) ParseCommand(args []string, writer CliWriter) ([]string, error) {
+	return self.parse(args, writer, nil, true)
+}
+
+func (self *CliParser) Check(args []string, isPlaceholder func(string) bool) ([]string, error) {
+	if isPlaceholder == nil {
+		isPlaceholder = func(string) bool { return false }
+	}
+	return self.parse(args, NewDummyWriter(), isPlaceholder, false)
+}
+
+func (self *CliParser) parse(args []string, writer CliWriter, isPlaceholder func(string) bool, allowHelp bool) ([]string, error) {
 	logger.Debugf("parsing %v", args)
-	args, err := self.parseOptions(args, writer)
+	args, err := self.parseOptions(args, writer, isPlaceholder, allowHelp)
 	if err != nil {
 		return args, err
 	}
 
+	if len(args) > 0 && args[0] == EndOfOptions {
+		logger.Debugf("detected end of options")
+		args = args[1:]
+		if len(self.arguments) == 0 {
+			return args, nil
+		}
+		return self.parseArguments(args, true, writer, isPlaceholder)
+	}
+
 	if len(self.arguments) == 0 {
 		return args, nil
 	}
 
-	args, err = self.parseArguments(args, writer)
+	args, err = self.parseArguments(args, false, writer, isPlaceholder)
 	return args, err
 }
 
-func (self *CliParser) parseOptions(args []string, writer CliWriter) ([]string, error) {
+func (self *CliParser) parseOptions(args []string, writer CliWriter, isPlaceholder func(string) bool, allowHelp bool) ([]string, error) {
+	counts := make(map[string]int)
 	for len(args) > 0 {
 		arg := args[0]
+		if arg == EndOfOptions {
+			break
+		}
+
 		token, err := parseArgToken(arg)
 		if err != nil {
 			return args, err
@@ -213,41 +493,105 @@ func (self *CliParser) parseOptions(args []string, writer CliWriter) ([]string,
 		if token.kind == ValueArg {
 			break
 		}
+		args = args[1:]
 
-		option, ok := self.findOption(token)
-		if !ok {
-			return args, util.NewError("invalid option \"%s\"", token.value)
+		tokens := []ArgToken{token}
+		if token.kind == ShortOpt {
+			tokens = token.shortOptions()
 		}
 
-		if option.kind == FlagOpt {
-			args = args[1:]
-			err = writer.Write(option.name)
+		for _, token := range tokens {
+			option, ok := self.findOption(token)
+			if !ok && allowHelp && self.isHelpToken(token) {
+				return args, ErrHelpRequested
+			}
+			if negated, isNegated := self.findNegatedOption(token); !ok && isNegated {
+				option, ok = negated, true
+			}
+			if !ok {
+				return args, util.NewError("invalid option \"%s\"", token.value)
+			}
+
+			if !option.takesValue() && token.kind == LongOpt && token.hasValue {
+				return args, util.NewError("option \"%s\" doesn't accept a value", option.name)
+			}
+
+			if option.kind == FlagOpt {
+				if option.name != token.value && token.kind == LongOpt {
+					err = writer.Unset(option.name)
+				} else {
+					err = writer.Write(option.name)
+				}
+				if err != nil {
+					return args, err
+				}
+				continue
+			}
+
+			if option.kind == CountOpt {
+				counts[option.name] += 1
+				err = writer.Write(option.name, strconv.Itoa(counts[option.name]))
+				if err != nil {
+					return args, err
+				}
+				continue
+			}
+
+			value := token.attached
+			if !token.hasValue {
+				value, args, err = takeOptionValue(option, args)
+				if err != nil {
+					return args, err
+				}
+			}
+
+			if !skipCheck(isPlaceholder, value) {
+				err = option.check(value, self.baseDir, isStatic(isPlaceholder))
+				if err != nil {
+					return args, err
+				}
+			}
+
+			if option.kind == ListOpt {
+				err = writer.Append(option.name, value)
+			} else {
+				err = writer.Write(option.name, value)
+			}
 			if err != nil {
 				return args, err
 			}
 
-			continue
+			if token.kind == ShortOpt {
+				break
+			}
 		}
+	}
+	return args, nil
+}
 
-		if len(args) < 2 {
-			return args, util.NewError("missing value for option \"%s\"", option.name)
-		}
-		valToken, err := parseArgToken(args[1])
-		if err != nil {
-			return args, err
-		}
+func takeOptionValue(option Option, args []string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", args, util.NewError("missing value for option \"%s\"", option.name)
+	}
+	valToken, err := parseArgToken(args[0])
+	if err != nil {
+		return "", args, err
+	}
 
-		if valToken.kind != ValueArg {
-			return args, util.NewError("invalid value \"%s\" for option \"%s\"", args[1], option.name)
-		}
+	if valToken.kind != ValueArg {
+		return "", args, util.NewError("invalid value \"%s\" for option \"%s\"", args[0], option.name)
+	}
+	return valToken.value, args[1:], nil
+}
 
-		err = writer.Write(option.name, valToken.value)
-		if err != nil {
-			return args, err
-		}
-		args = args[2:]
+func (self *CliParser) isHelpToken(token ArgToken) bool {
+	if self.Contains(HelpName) {
+		return false
 	}
-	return args, nil
+	if token.kind == ShortOpt {
+		return token.value == HelpShortname
+	}
+	return token.kind == LongOpt && token.value == HelpName
 }
 
 func (self *CliParser) findOption(token ArgToken) (Option, bool) {
@@ -262,8 +606,21 @@ func (self *CliParser) findOption(token ArgToken) (Option, bool) {
 	return Option{}, false
 }
 
-func (self *CliParser) parseArguments(args []string, writer CliWriter) ([]string, error) {
-	valueTokens, err := getValueTokens(args)
+func (self *CliParser) findNegatedOption(token ArgToken) (Option, bool) {
+	name, ok := strings.CutPrefix(token.value, "no-")
+	if token.kind != LongOpt || !ok {
+		return Option{}, false
+	}
+	for _, option := range self.options {
+		if option.name == name && option.isNegatable() {
+			return option, true
+		}
+	}
+	return Option{}, false
+}
+
+func (self *CliParser) parseArguments(args []string, raw bool, writer CliWriter, isPlaceholder func(string) bool) ([]string, error) {
+	valueTokens, err := getValueTokens(args, raw)
 	if err != nil {
 		return args, err
 	}
@@ -275,6 +632,13 @@ func (self *CliParser) parseArguments(args []string, writer CliWriter) ([]string
 				return args, util.NewError("missing argment \"%s\"", argument.name)
 			}
 
+			if !skipCheck(isPlaceholder, valueTokens[0].value) {
+				err = argument.check(valueTokens[0].value, self.baseDir, isStatic(isPlaceholder))
+				if err != nil {
+					return args, err
+				}
+			}
+
 			writer.Write(argument.name, valueTokens[0].value)
 			argsConsumed += 1
 			valueTokens = valueTokens[1:]
@@ -290,21 +654,43 @@ func (self *CliParser) parseArguments(args []string, writer CliWriter) ([]string
 			return args, util.NewError("missing argument \"%s\"", argument.name)
 		}
 
-		values := []string{}
+		if tokensLeft == 0 {
+			continue
+		}
 
 		for _, token := range valueTokens {
-			values = append(values, token.value)
+			if !skipCheck(isPlaceholder, token.value) {
+				err = argument.check(token.value, self.baseDir, isStatic(isPlaceholder))
+				if err != nil {
+					return args, err
+				}
+			}
 		}
 		argsConsumed += len(valueTokens)
-		writer.Write(argument.name, strings.Join(values, " "))
+
+		if argument.kind == OptionalArg {
+			writer.Write(argument.name, valueTokens[0].value)
+			continue
+		}
+		for _, token := range valueTokens {
+			writer.Append(argument.name, token.value)
+		}
 	}
 	return args[argsConsumed:], nil
 }
 
-func getValueTokens(args []string) ([]ArgToken, error) {
+func getValueTokens(args []string, raw bool) ([]ArgToken, error) {
 	tokens := []ArgToken{}
 
 	for _, arg := range args {
+		if raw {
+			tokens = append(tokens, ArgToken{value: arg, kind: ValueArg})
+			continue
+		}
+		if arg == EndOfOptions {
+			break
+		}
+
 		token, err := parseArgToken(arg)
 		if err != nil {
 			return tokens, err
@@ -316,3 +702,19 @@ func getValueTokens(args []string) ([]ArgToken, error) {
 	}
 	return tokens, nil
 }
+
+func checkChoices(kind string, name string, choices []string, value string) error {
+	if len(choices) == 0 || slices.Contains(choices, value) {
+		return nil
+	}
+	allowed := strings.Join(choices, ", ")
+	return util.NewError("invalid value \"%s\" for %s \"%s\", allowed values: %s", value, kind, name, allowed)
+}
+
+func skipCheck(isPlaceholder func(string) bool, value string) bool {
+	return isPlaceholder != nil && isPlaceholder(value)
+}
+
+func isStatic(isPlaceholder func(string) bool) bool {
+	return isPlaceholder != nil
+}
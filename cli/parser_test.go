@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newTestParser(t *testing.T, specs ...string) *CliParser {
+	parser := NewCliParser()
+	for _, spec := range specs {
+		err := parser.Add(spec)
+		if err != nil {
+			t.Fatalf("can't add spec \"%s\": %s", spec, err)
+		}
+	}
+	return parser
+}
+
+func TestParseChoices(t *testing.T) {
+	parser := newTestParser(t, "env|e=value[dev,prod](dev)", "mode[fast,slow]")
+
+	testCases := []struct {
+		args   []string
+		values map[string]string
+	}{
+		{[]string{"fast"}, map[string]string{"env": "dev", "mode": "fast"}},
+		{[]string{"-e", "prod", "slow"}, map[string]string{"env": "prod", "mode": "slow"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%v", tc.args), func(t *testing.T) {
+			values := parser.Defaults("x")
+			_, err := parser.Parse(tc.args, NewMapWriter(values, "x"))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			for name, value := range tc.values {
+				if values[name] != value {
+					t.Errorf("got %s=%s; want %s", name, values[name], value)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidChoices(t *testing.T) {
+	parser := newTestParser(t, "env|e=value[dev,prod]", "mode[fast,slow]")
+
+	testCases := [][]string{
+		{"medium"},
+		{"-e", "staging", "fast"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%v", tc), func(t *testing.T) {
+			_, err := parser.Parse(tc, NewDummyWriter())
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestInvalidSpecs(t *testing.T) {
+	specs := []string{
+		"flag|f=flag(x)",
+		"arg(default)",
+		"env|e=value[dev,prod](staging)",
+		"flag|f=flag[a,b]",
+		"flag|f=flag:int",
+		"count:number",
+		"count?:int(many)",
+	}
+
+	for _, spec := range specs {
+		t.Run(spec, func(t *testing.T) {
+			err := NewCliParser().Add(spec)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestTypedValues(t *testing.T) {
+	parser := newTestParser(t, "timeout|t=value:duration", "force|f=value:bool", "count:int")
+
+	testCases := []struct {
+		args  []string
+		valid bool
+	}{
+		{[]string{"3"}, true},
+		{[]string{"-t", "1m30s", "-f", "true", "-3"}, true},
+		{[]string{"-t", "1m30s", "-f", "true", "-x"}, false},
+		{[]string{"-t", "1m30s", "-f", "true", "10"}, true},
+		{[]string{"abc"}, false},
+		{[]string{"-t", "90", "1"}, false},
+		{[]string{"-f", "maybe", "1"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%v", tc.args), func(t *testing.T) {
+			_, err := parser.Parse(tc.args, NewDummyWriter())
+			if tc.valid && err != nil {
+				t.Fatal(err.Error())
+			}
+			if !tc.valid && err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestGnuOptions(t *testing.T) {
+	parser := newTestParser(t, "verbose|v=flag", "dry|d=flag", "env|e=value", "name|n=value", "target?")
+
+	testCases := []struct {
+		args   []string
+		values map[string]string
+	}{
+		{[]string{"-vd"}, map[string]string{"verbose": "x", "dry": "x"}},
+		{[]string{"-vdeprod"}, map[string]string{"verbose": "x", "dry": "x", "env": "prod"}},
+		{[]string{"-ve", "prod", "build"}, map[string]string{"verbose": "x", "env": "prod", "target": "build"}},
+		{[]string{"-nfoo", "--env=dev"}, map[string]string{"name": "foo", "env": "dev"}},
+		{[]string{"--env=-5", "--name=a=b"}, map[string]string{"env": "-5", "name": "a=b"}},
+		{[]string{"--env=", "-d"}, map[string]string{"env": "", "dry": "x"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%v", tc.args), func(t *testing.T) {
+			values := make(map[string]string)
+			_, err := parser.Parse(tc.args, NewMapWriter(values, "x"))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			for name, value := range tc.values {
+				if got, ok := values[name]; !ok || got != value {
+					t.Errorf("got %s=%s; want %s", name, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidGnuOptions(t *testing.T) {
+	parser := newTestParser(t, "verbose|v=flag", "env|e=value")
+
+	testCases := [][]string{
+		{"-vx"},
+		{"--verbose=yes"},
+		{"-ve"},
+		{"--=value"},
+		{"-ve", "-v"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%v", tc), func(t *testing.T) {
+			_, err := parser.Parse(tc, NewDummyWriter())
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestEndOfOptions(t *testing.T) {
+	parser := newTestParser(t, "verbose|v=flag", "offset|o=value:int", "patterns*")
+
+	testCases := []struct {
+		args   []string
+		values map[string]string
+	}{
+		{[]string{"--", "-v"}, map[string]string{"patterns": "-v"}},
+		{[]string{"-v", "--", "--", "-o"}, map[string]string{"verbose": "x", "patterns": "-- -o"}},
+		{[]string{"-o", "-5", "-1.5", "2"}, map[string]string{"offset": "-5", "patterns": "-1.5 2"}},
+		{[]string{"--"}, map[string]string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%v", tc.args), func(t *testing.T) {
+			values := make(map[string]string)
+			leftover, err := parser.Parse(tc.args, NewMapWriter(values, "x"))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if len(leftover) > 0 {
+				t.Fatalf("unexpected leftover arguments %v", leftover)
+			}
+			for name, value := range tc.values {
+				if got, ok := values[name]; !ok || got != value {
+					t.Errorf("got %s=%s; want %s", name, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestListOptions(t *testing.T) {
+	parser := newTestParser(t, "tag|t=list", "port|p=list:int")
+
+	values := make(map[string]string)
+	writer := NewMapWriter(values, "x")
+	_, err := parser.Parse([]string{"-t", "a b", "--tag=c", "-tc", "-p", "80", "-p443"}, writer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if values["tag"] != "a b c c" {
+		t.Errorf("got tag=%s; want \"a b c c\"", values["tag"])
+	}
+
+	lists := writer.Lists()
+	if fmt.Sprintf("%q", lists["tag"]) != `["a b" "c" "c"]` {
+		t.Errorf("got tag list %q", lists["tag"])
+	}
+	if fmt.Sprintf("%q", lists["port"]) != `["80" "443"]` {
+		t.Errorf("got port list %q", lists["port"])
+	}
+
+	_, err = parser.Parse([]string{"-p", "http"}, NewDummyWriter())
+	if err == nil {
+		t.Error("expected error for invalid list value")
+	}
+}
+
+func TestNegatableAndCountedFlags(t *testing.T) {
+	parser := newTestParser(t, "color|c=flag(true)", "verbose|v=count", "quiet|q=flag")
+
+	testCases := []struct {
+		args   []string
+		values map[string]string
+	}{
+		{[]string{}, map[string]string{"color": "x", "verbose": "0", "quiet": ""}},
+		{[]string{"--no-color", "-vvv"}, map[string]string{"color": "", "verbose": "3"}},
+		{[]string{"-vq", "--verbose", "-c"}, map[string]string{"color": "x", "verbose": "2", "quiet": "x"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%v", tc.args), func(t *testing.T) {
+			values := parser.Defaults("x")
+			_, err := parser.Parse(tc.args, NewMapWriter(values, "x"))
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			for name, value := range tc.values {
+				if values[name] != value {
+					t.Errorf("got %s=%s; want %s", name, values[name], value)
+				}
+			}
+		})
+	}
+
+	for _, args := range [][]string{{"--no-quiet"}, {"--verbose=2"}, {"--no-color=1"}} {
+		_, err := parser.Parse(args, NewDummyWriter())
+		if err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
+
+func TestVariadicValues(t *testing.T) {
+	parser := newTestParser(t, "first", "rest*")
+
+	values := make(map[string]string)
+	writer := NewMapWriter(values, "x")
+	_, err := parser.Parse([]string{"a", "b c", "d"}, writer)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if values["rest"] != "b c d" {
+		t.Errorf("got rest=%s; want \"b c d\"", values["rest"])
+	}
+	if fmt.Sprintf("%q", writer.Lists()["rest"]) != `["b c" "d"]` {
+		t.Errorf("got rest list %q", writer.Lists()["rest"])
+	}
+}
+
+func TestPathValues(t *testing.T) {
+	parser := newTestParser(t, "config|c=value:file", "out:dir")
+	parser.SetBaseDir(t.TempDir())
+
+	_, err := parser.Check([]string{"-c", "missing.yml", "build"}, nil)
+	if err != nil {
+		t.Errorf("check: unexpected error \"%s\"", err)
+	}
+
+	_, err = parser.Check([]string{"-c", "", "build"}, nil)
+	if err == nil {
+		t.Errorf("check: expected error for empty path")
+	}
+
+	_, err = parser.Parse([]string{"-c", "missing.yml", "."}, NewDummyWriter())
+	if err == nil {
+		t.Errorf("parse: expected error for missing file")
+	}
+
+	_, err = parser.Parse([]string{"build"}, NewDummyWriter())
+	if err == nil {
+		t.Errorf("parse: expected error for missing directory")
+	}
+}
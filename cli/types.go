@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"hund/util"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+type ValueType int
+
+const (
+	StringType   ValueType = iota
+	IntType      ValueType = iota
+	BoolType     ValueType = iota
+	PathType     ValueType = iota
+	FileType     ValueType = iota
+	DirType      ValueType = iota
+	DurationType ValueType = iota
+)
+
+var valueTypeNames = map[ValueType]string{
+	StringType:   "string",
+	IntType:      "int",
+	BoolType:     "bool",
+	PathType:     "path",
+	FileType:     "file",
+	DirType:      "dir",
+	DurationType: "duration",
+}
+
+func parseValueType(name string) (ValueType, error) {
+	for valueType, typeName := range valueTypeNames {
+		if typeName == name {
+			return valueType, nil
+		}
+	}
+	return StringType, util.NewError("unknown type \"%s\"", name)
+}
+
+func (self ValueType) String() string {
+	return valueTypeNames[self]
+}
+
+func checkFormat(kind string, name string, valueType ValueType, value string) error {
+	switch valueType {
+	case IntType:
+		_, err := strconv.Atoi(value)
+		if err != nil {
+			return util.NewError("%s %s expects an integer, got '%s'", kind, name, value)
+		}
+	case BoolType:
+		_, err := strconv.ParseBool(value)
+		if err != nil {
+			return util.NewError("%s %s expects a boolean, got '%s'", kind, name, value)
+		}
+	case DurationType:
+		_, err := time.ParseDuration(value)
+		if err != nil {
+			return util.NewError("%s %s expects a duration, got '%s'", kind, name, value)
+		}
+	case PathType, FileType, DirType:
+		if value == "" {
+			return util.NewError("%s %s expects a path, got ''", kind, name)
+		}
+	}
+	return nil
+}
+
+func checkType(kind string, name string, valueType ValueType, value string, baseDir string) error {
+	err := checkFormat(kind, name, valueType, value)
+	if err != nil {
+		return err
+	}
+
+	if valueType != FileType && valueType != DirType {
+		return nil
+	}
+
+	path := value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+
+	info, err := os.Stat(path)
+	if valueType == FileType && (err != nil || info.IsDir()) {
+		return util.NewError("%s %s expects an existing file, got '%s'", kind, name, value)
+	}
+	if valueType == DirType && (err != nil || !info.IsDir()) {
+		return util.NewError("%s %s expects an existing directory, got '%s'", kind, name, value)
+	}
+	return nil
+}
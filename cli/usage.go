@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+type ArgumentInfo struct {
+	Name    string   `json:"name"`
+	Kind    string   `json:"kind"`
+	Type    string   `json:"type"`
+	Default string   `json:"default,omitempty"`
+	Choices []string `json:"choices,omitempty"`
+}
+
+type OptionInfo struct {
+	Name      string   `json:"name"`
+	Shortname string   `json:"shortname,omitempty"`
+	Kind      string   `json:"kind"`
+	Type      string   `json:"type,omitempty"`
+	Default   string   `json:"default,omitempty"`
+	Choices   []string `json:"choices,omitempty"`
+}
+
+func (self ArgumentKind) String() string {
+	switch self {
+	case OptionalArg:
+		return "optional"
+	case AtLeastOneArg:
+		return "at-least-one"
+	case AnyArg:
+		return "any"
+	default:
+		return "single"
+	}
+}
+
+func (self OptionKind) String() string {
+	switch self {
+	case ValueOpt:
+		return "value"
+	case ListOpt:
+		return "list"
+	case CountOpt:
+		return "count"
+	default:
+		return "flag"
+	}
+}
+
+func (self Argument) usage() string {
+	switch self.kind {
+	case OptionalArg:
+		return fmt.Sprintf("[%s]", self.name)
+	case AtLeastOneArg:
+		return fmt.Sprintf("<%s>...", self.name)
+	case AnyArg:
+		return fmt.Sprintf("[%s...]", self.name)
+	default:
+		return fmt.Sprintf("<%s>", self.name)
+	}
+}
+
+func (self Option) names() string {
+	names := "--" + self.name
+	if self.shortname != "" {
+		names = fmt.Sprintf("-%s|--%s", self.shortname, self.name)
+	}
+	if self.isNegatable() {
+		names += "|--no-" + self.name
+	}
+	return names
+}
+
+func (self Option) placeholder() string {
+	if self.valueType == StringType {
+		return "VALUE"
+	}
+	return strings.ToUpper(self.valueType.String())
+}
+
+func (self Option) usage() string {
+	if self.kind == FlagOpt {
+		return fmt.Sprintf("[%s]", self.names())
+	}
+	if self.kind == CountOpt {
+		return fmt.Sprintf("[%s]...", self.names())
+	}
+	if self.kind == ListOpt {
+		return fmt.Sprintf("[%s %s]...", self.names(), self.placeholder())
+	}
+	return fmt.Sprintf("[%s %s]", self.names(), self.placeholder())
+}
+
+func (self *CliParser) Usage() string {
+	result := []string{}
+
+	for _, option := range self.options {
+		result = append(result, option.usage())
+	}
+
+	for _, argument := range self.arguments {
+		result = append(result, argument.usage())
+	}
+
+	return strings.Join(result, " ")
+}
+
+func (self *CliParser) Arguments() []ArgumentInfo {
+	result := []ArgumentInfo{}
+
+	for _, argument := range self.arguments {
+		info := ArgumentInfo{
+			Name:    argument.name,
+			Kind:    argument.kind.String(),
+			Type:    argument.valueType.String(),
+			Default: argument.defaultValue,
+			Choices: argument.choices,
+		}
+		result = append(result, info)
+	}
+
+	return result
+}
+
+func (self *CliParser) Options() []OptionInfo {
+	result := []OptionInfo{}
+
+	for _, option := range self.options {
+		info := OptionInfo{
+			Name:      option.name,
+			Shortname: option.shortname,
+			Kind:      option.kind.String(),
+			Default:   option.defaultValue,
+			Choices:   option.choices,
+		}
+		if option.takesValue() {
+			info.Type = option.valueType.String()
+		}
+		result = append(result, info)
+	}
+
+	return result
+}
+
+func (self *CliParser) hasAutoHelp() bool {
+	return !self.Contains(HelpName)
+}
+
+func (self *CliParser) Help() string {
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 8, 2, ' ', 0)
+
+	if len(self.arguments) > 0 {
+		fmt.Fprintln(writer, "arguments:")
+		for _, argument := range self.arguments {
+			fmt.Fprintf(writer, "  %s\t%s\n", argument.name, argument.describe())
+		}
+	}
+
+	if len(self.arguments) > 0 && (len(self.options) > 0 || self.hasAutoHelp()) {
+		fmt.Fprintln(writer)
+	}
+
+	if len(self.options) > 0 || self.hasAutoHelp() {
+		fmt.Fprintln(writer, "options:")
+	}
+	for _, option := range self.options {
+		fmt.Fprintf(writer, "  %s\t%s\n", option.helpNames(), option.describe())
+	}
+	if self.hasAutoHelp() {
+		help := Option{name: HelpName, kind: FlagOpt}
+		if _, ok := self.findOption(ArgToken{value: HelpShortname, kind: ShortOpt}); !ok {
+			help.shortname = HelpShortname
+		}
+		fmt.Fprintf(writer, "  %s\t%s\n", help.helpNames(), "show this help and exit")
+	}
+
+	writer.Flush()
+	return strings.TrimRight(builder.String(), "\n")
+}
+
+func (self Argument) describe() string {
+	details := []string{}
+	switch self.kind {
+	case OptionalArg:
+		details = append(details, "optional, accepts at most one value")
+	case AtLeastOneArg:
+		details = append(details, "required, accepts one or more values")
+	case AnyArg:
+		details = append(details, "optional, accepts any number of values")
+	default:
+		details = append(details, "required, accepts single value")
+	}
+	details = append(details, describeValue(self.valueType, self.choices, self.defaultValue)...)
+	return strings.Join(details, ", ")
+}
+
+func (self Option) helpNames() string {
+	names := "--" + self.name
+	if self.shortname != "" {
+		names = fmt.Sprintf("-%s, --%s", self.shortname, self.name)
+	}
+	if self.isNegatable() {
+		names += ", --no-" + self.name
+	}
+	if self.takesValue() {
+		names += " " + self.placeholder()
+	}
+	return names
+}
+
+func (self Option) describe() string {
+	if self.isNegatable() {
+		return "flag, enabled by default"
+	}
+	if self.kind == FlagOpt {
+		return "flag"
+	}
+	if self.kind == CountOpt {
+		return "counter, can be repeated"
+	}
+	details := []string{"value"}
+	if self.kind == ListOpt {
+		details = []string{"list, can be repeated"}
+	}
+	details = append(details, describeValue(self.valueType, self.choices, self.defaultValue)...)
+	return strings.Join(details, ", ")
+}
+
+func describeValue(valueType ValueType, choices []string, defaultValue string) []string {
+	details := []string{}
+	if valueType != StringType {
+		details = append(details, fmt.Sprintf("type: %s", valueType))
+	}
+	if len(choices) > 0 {
+		details = append(details, fmt.Sprintf("allowed values: %s", strings.Join(choices, " | ")))
+	}
+	if defaultValue != "" {
+		details = append(details, fmt.Sprintf("default: %s", defaultValue))
+	}
+	return details
+}
@@ -8,6 +8,8 @@ import (
 
 type CliWriter interface {
 	Write(name string, value ...string) error
+	Append(name string, value string) error
+	Unset(name string) error
 }
 
 type DummyWriter struct{}
@@ -21,13 +23,25 @@ func (self *DummyWriter) Write(name string, value ...string) error {
 	return nil
 }
 
+func (self *DummyWriter) Unset(name string) error {
+	logger.Debugf("unsetting %s", name)
+	return nil
+}
+
+func (self *DummyWriter) Append(name string, value string) error {
+	logger.Debugf("appending \"%s\" to %s", value, name)
+	return nil
+}
+
 type MapWriter struct {
 	values    map[string]string
+	lists     map[string][]string
 	flagValue string
 }
 
 func NewMapWriter(values map[string]string, flagValue string) *MapWriter {
-	return &MapWriter{values, flagValue}
+	lists := make(map[string][]string)
+	return &MapWriter{values, lists, flagValue}
 }
 
 func (self *MapWriter) Write(name string, value ...string) error {
@@ -42,15 +56,34 @@ func (self *MapWriter) Write(name string, value ...string) error {
 	return nil
 }
 
+func (self *MapWriter) Unset(name string) error {
+	logger.Debugf("unsetting param \"%s\"\n", name)
+	self.values[name] = ""
+	return nil
+}
+
+func (self *MapWriter) Append(name string, value string) error {
+	logger.Debugf("appending \"%s\" to \"%s\"\n", value, name)
+	self.lists[name] = append(self.lists[name], value)
+	self.values[name] = strings.Join(self.lists[name], " ")
+	return nil
+}
+
+func (self *MapWriter) Lists() map[string][]string {
+	return self.lists
+}
+
 type PointerWriter struct {
 	flags  map[string]*bool
 	values map[string]*string
+	lists  map[string]*[]string
 }
 
 func NewPointerWriter() *PointerWriter {
 	flags := make(map[string]*bool)
 	values := make(map[string]*string)
-	return &PointerWriter{flags, values}
+	lists := make(map[string]*[]string)
+	return &PointerWriter{flags, values, lists}
 }
 
 func (self *PointerWriter) Write(name string, value ...string) error {
@@ -72,6 +105,31 @@ func (self *PointerWriter) Write(name string, value ...string) error {
 	return nil
 }
 
+func (self *PointerWriter) Unset(name string) error {
+	logger.Debugf("unsetting option \"%s\"\n", name)
+	if p, ok := self.flags[name]; ok {
+		*p = false
+		return nil
+	}
+
+	p, ok := self.values[name]
+	if !ok {
+		return util.NewError("missing \"%s\" option", name)
+	}
+	*p = ""
+	return nil
+}
+
+func (self *PointerWriter) Append(name string, value string) error {
+	logger.Debugf("appending \"%s\" to option \"%s\"\n", value, name)
+	p, ok := self.lists[name]
+	if !ok {
+		return util.NewError("missing \"%s\" list", name)
+	}
+	*p = append(*p, value)
+	return nil
+}
+
 func (self *PointerWriter) AddFlag(name string, target *bool) error {
 	_, ok := self.flags[name]
 	if ok {
@@ -90,3 +148,12 @@ func (self *PointerWriter) AddValue(name string, target *string) error {
 	self.values[name] = target
 	return nil
 }
+
+func (self *PointerWriter) AddList(name string, target *[]string) error {
+	_, ok := self.lists[name]
+	if ok {
+		return util.NewError("key \"%s\" already added", name)
+	}
+	self.lists[name] = target
+	return nil
+}
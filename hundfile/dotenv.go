@@ -0,0 +1,125 @@
+package hundfile
+
+import (
+	"bufio"
+	"errors"
+	"hund/logger"
+	"hund/util"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var dotenvKeyExpression = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func (self *Hundfile) loadDotenv(args string) error {
+	for _, name := range strings.Split(args, ",") {
+		name = strings.TrimSpace(name)
+		optional := strings.HasSuffix(name, "?")
+		name = strings.TrimSuffix(name, "?")
+		if name == "" {
+			return util.NewError("empty file name in directive @dotenv")
+		}
+
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(self.Dir, path)
+		}
+
+		values, err := ReadDotenv(path)
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			logger.Debugf("optional dotenv file \"%s\" not found, skipping", path)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		logger.Debugf("loaded %d values from \"%s\"", len(values), path)
+		for key, value := range values {
+			self.Env[key] = value
+		}
+	}
+	return nil
+}
+
+func ReadDotenv(path string) (map[string]string, error) {
+	result := make(map[string]string)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return result, err
+	}
+	defer f.Close()
+
+	num := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		num += 1
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		text = strings.TrimPrefix(text, "export ")
+		key, value, ok := strings.Cut(text, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !dotenvKeyExpression.MatchString(key) {
+			return result, util.NewError("%s: line %d: expected KEY=value", path, num)
+		}
+
+		value, err = parseDotenvValue(strings.TrimSpace(value))
+		if err != nil {
+			return result, util.NewError("%s: line %d: %w", path, num, err)
+		}
+		result[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func parseDotenvValue(value string) (string, error) {
+	if strings.HasPrefix(value, "'") {
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", util.NewError("missing closing quote")
+		}
+		return value[1 : end+1], nil
+	}
+
+	if strings.HasPrefix(value, "\"") {
+		var builder strings.Builder
+		escaped := false
+		for _, ch := range value[1:] {
+			if escaped {
+				switch ch {
+				case 'n':
+					builder.WriteRune('\n')
+				case 't':
+					builder.WriteRune('\t')
+				default:
+					builder.WriteRune(ch)
+				}
+				escaped = false
+				continue
+			}
+			if ch == '\\' {
+				escaped = true
+				continue
+			}
+			if ch == '"' {
+				return builder.String(), nil
+			}
+			builder.WriteRune(ch)
+		}
+		return "", util.NewError("missing closing quote")
+	}
+
+	value, _, _ = strings.Cut(value, " #")
+	return strings.TrimSpace(value), nil
+}
@@ -0,0 +1,44 @@
+package hundfile
+
+import (
+	"testing"
+)
+
+func TestDotenvValue(t *testing.T) {
+	testCases := []struct {
+		text  string
+		value string
+	}{
+		{"plain", "plain"},
+		{"plain # comment", "plain"},
+		{"'single $quoted'", "single $quoted"},
+		{"\"double\\n\\\"quoted\\\"\"", "double\n\"quoted\""},
+		{"\"with # hash\"", "with # hash"},
+		{"", ""},
+	}
+
+	for _, tc := range testCases {
+		value, err := parseDotenvValue(tc.text)
+		if err != nil {
+			t.Errorf("text \"%s\": %s", tc.text, err)
+			continue
+		}
+		if value != tc.value {
+			t.Errorf("got \"%s\"; want \"%s\"", value, tc.value)
+		}
+	}
+}
+
+func TestDotenvUnterminated(t *testing.T) {
+	texts := []string{
+		"'single",
+		"\"double",
+	}
+
+	for _, text := range texts {
+		_, err := parseDotenvValue(text)
+		if err == nil {
+			t.Errorf("text \"%s\" expected error", text)
+		}
+	}
+}
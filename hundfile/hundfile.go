@@ -3,6 +3,8 @@ package hundfile
 import (
 	"fmt"
 	"hund/util"
+	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -13,13 +15,25 @@ type Hundfile struct {
 	ShellArgs []string
 	EmbedSep  string
 	FlagValue string
+	AutoQuote bool
+	Variables map[string]string
+	Env       map[string]string
+	Dir       string
+	Cwd       string
 }
 
+const INVOCATION_DIR = "invocation_dir"
+
+var variableNameExpression = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+
 func NewHundfile() Hundfile {
 	return Hundfile{
 		Shell:     "/bin/sh",
 		EmbedSep:  ";",
 		FlagValue: "x",
+		Variables: make(map[string]string),
+		Env:       make(map[string]string),
+		Dir:       ".",
 	}
 }
 
@@ -42,6 +56,23 @@ func (self Hundfile) GetTarget(targetName string) (Target, error) {
 	return Target{}, util.NewError("could not find target \"%s\"", targetName)
 }
 
+func (self Hundfile) ResolveTarget(targetName string, namespace string) (Target, error) {
+	for namespace != "" {
+		target, err := self.GetTarget(namespace + NamespaceSep + targetName)
+		if err == nil {
+			return target, nil
+		}
+
+		index := strings.LastIndex(namespace, NamespaceSep)
+		if index < 0 {
+			namespace = ""
+		} else {
+			namespace = namespace[:index]
+		}
+	}
+	return self.GetTarget(targetName)
+}
+
 func (self *Hundfile) ApplyGlobal(name string, args string) error {
 	switch name {
 	case "shell":
@@ -55,6 +86,25 @@ func (self *Hundfile) ApplyGlobal(name string, args string) error {
 		self.EmbedSep = args
 	case "flagValue":
 		self.FlagValue = args
+	case "quote":
+		switch strings.TrimSpace(args) {
+		case "auto":
+			self.AutoQuote = true
+		case "none":
+			self.AutoQuote = false
+		default:
+			return util.NewError("invalid argument \"%s\" to directive @quote, expected auto or none", args)
+		}
+	case "var":
+		return self.addVariable(args)
+	case "dotenv":
+		return self.loadDotenv(args)
+	case "cwd":
+		cwd := strings.TrimSpace(args)
+		if cwd == "" {
+			return util.NewError("too few arguments to directive @cwd")
+		}
+		self.Cwd = cwd
 
 	default:
 		return util.NewError("invalid global directive %s", name)
@@ -62,6 +112,31 @@ func (self *Hundfile) ApplyGlobal(name string, args string) error {
 	return nil
 }
 
+func (self *Hundfile) addVariable(args string) error {
+	name, value, ok := strings.Cut(args, "=")
+	name = strings.TrimSpace(name)
+	if !ok {
+		return util.NewError("expected NAME=value in directive @var, got \"%s\"", args)
+	}
+	if !variableNameExpression.MatchString(name) {
+		return util.NewError("invalid variable name \"%s\"", name)
+	}
+	if name == INVOCATION_DIR {
+		return util.NewError("\"%s\" is a built-in variable", name)
+	}
+	if _, defined := self.Variables[name]; defined {
+		return util.NewError("variable \"%s\" already defined", name)
+	}
+
+	self.Variables[name] = strings.TrimSpace(value)
+	return nil
+}
+
+func (self Hundfile) HasVariable(name string) bool {
+	_, ok := self.Variables[name]
+	return ok
+}
+
 func (self Hundfile) String() string {
 	globals := strings.Join(self.Globals, ", ")
 
@@ -77,8 +152,20 @@ func (self Hundfile) String() string {
 	}
 	shellArgsStr := strings.Join(shellArgs, ", ")
 
+	names := []string{}
+	for name := range self.Variables {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	variables := []string{}
+	for _, name := range names {
+		variables = append(variables, fmt.Sprintf("%s=%s", name, util.Quote(self.Variables[name])))
+	}
+	variablesStr := strings.Join(variables, ", ")
+
 	return fmt.Sprintf(
-		"Shell: \"%s\"\nShellArgs: [%s]\nEmbedSep: \"%s\"\nFlagValue: \"%s\"\nGlobals: [%s]\nTargets: [%s]",
-		self.Shell, shellArgsStr, self.EmbedSep, self.FlagValue, globals, targets,
+		"Shell: \"%s\"\nShellArgs: [%s]\nEmbedSep: \"%s\"\nFlagValue: \"%s\"\nDir: \"%s\"\nCwd: \"%s\"\nVariables: [%s]\nGlobals: [%s]\nTargets: [%s]",
+		self.Shell, shellArgsStr, self.EmbedSep, self.FlagValue, self.Dir, self.Cwd, variablesStr, globals, targets,
 	)
 }
@@ -0,0 +1,73 @@
+package hundfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"hund/cli"
+	"slices"
+	"strings"
+)
+
+type TargetInfo struct {
+	Name          string             `json:"name"`
+	Description   string             `json:"description"`
+	Usage         string             `json:"usage"`
+	Prerequisites []string           `json:"prerequisites"`
+	Arguments     []cli.ArgumentInfo `json:"arguments"`
+	Options       []cli.OptionInfo   `json:"options"`
+}
+
+func (self Hundfile) List(sorted bool, asJson bool) (string, error) {
+	targets := slices.Clone(self.Targets)
+	if sorted {
+		slices.SortFunc(targets, func(a Target, b Target) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+	}
+
+	if asJson {
+		return listJson(targets)
+	}
+
+	lines := []string{}
+	for _, target := range targets {
+		lines = append(lines, target.Usage())
+		if target.Description == "" {
+			continue
+		}
+		for _, line := range strings.Split(target.Description, "\n") {
+			lines = append(lines, "    "+line)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func listJson(targets []Target) (string, error) {
+	infos := []TargetInfo{}
+	for _, target := range targets {
+		prerequisites := target.Prerequisites
+		if prerequisites == nil {
+			prerequisites = []string{}
+		}
+
+		info := TargetInfo{
+			Name:          target.Name,
+			Description:   target.Description,
+			Usage:         target.Usage(),
+			Prerequisites: prerequisites,
+			Arguments:     target.Parser.Arguments(),
+			Options:       target.Parser.Options(),
+		}
+		infos = append(infos, info)
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(infos)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buffer.String()), nil
+}
@@ -11,12 +11,17 @@ type Options struct {
 	VerboseMode      bool
 	DryRun           bool
 	ShowHelp         bool
+	ListTargets      bool
+	SortTargets      bool
+	JsonOutput       bool
+	CrossGit         bool
 }
 
 func (self Options) String() string {
 	return fmt.Sprintf(
-		"ProgramName: \"%s\"\nScriptsDirectory: \"%s\"\nHundfileName: \"%s\"\nVerboseMode: %v\nDryRun: %v\nShowHelp: %v",
+		"ProgramName: \"%s\"\nScriptsDirectory: \"%s\"\nHundfileName: \"%s\"\nVerboseMode: %v\nDryRun: %v\nShowHelp: %v\nListTargets: %v\nSortTargets: %v\nJsonOutput: %v\nCrossGit: %v",
 		self.ProgramName, self.ScriptsDirectory, self.HundfileName, self.VerboseMode, self.DryRun, self.ShowHelp,
+		self.ListTargets, self.SortTargets, self.JsonOutput, self.CrossGit,
 	)
 }
 
@@ -24,10 +29,14 @@ func NewOptions() Options {
 	opt := Options{
 		ProgramName:      "hund",
 		ScriptsDirectory: "/tmp",
-		HundfileName:     "Hundfile",
+		HundfileName:     "",
 		VerboseMode:      false,
 		DryRun:           false,
 		ShowHelp:         false,
+		ListTargets:      false,
+		SortTargets:      false,
+		JsonOutput:       false,
+		CrossGit:         false,
 	}
 	return opt
 }
@@ -36,10 +45,14 @@ func (self Options) GetHelp() string {
 	result := fmt.Sprintf("%s [options] target-name [target-options] target-args\n", self.ProgramName)
 	result += "\n"
 	result += "options:\n"
-	result += "--filename, -f value\tpath to a Hundfile\n"
+	result += "--filename, -f value\tpath to a Hundfile, by default it is searched for in current and parent directories\n"
+	result += "--cross-git\t\tdon't stop searching for a Hundfile at git repository root\n"
 	result += "--temp-dir, -t value\tpath to a directory storing rendered script before execution\n"
 	result += "--verbose, -v\t\tshow verbose information about program execution\n"
 	result += "--dry-run, -d\t\trender and print script, don't run it\n"
+	result += "--list, -l\t\tlist targets defined in a Hundfile and exit\n"
+	result += "--sort, -s\t\tsort listed targets by name instead of file order\n"
+	result += "--json\t\t\tprint target list as JSON\n"
 	result += "--help, -h\t\tshow this help and exit\n"
 	return result
 }
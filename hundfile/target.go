@@ -4,12 +4,31 @@ import (
 	"fmt"
 	"hund/cli"
 	"hund/util"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
+const NamespaceSep = ":"
+
 type Target struct {
-	Name   string
-	Parser *cli.CliParser
-	Script string
+	Name          string
+	Namespace     string
+	Description   string
+	Parser        *cli.CliParser
+	Variables     map[string]string
+	Dotenv        map[string]string
+	Env           map[string]string
+	Shell         string
+	ShellArgs     []string
+	AutoQuote     bool
+	FlagValue     string
+	EmbedSep      string
+	Dir           string
+	Cwd           string
+	Prerequisites []string
+	Script        string
+	Source        string
 }
 
 func NewTarget() Target {
@@ -21,8 +40,127 @@ func NewTarget() Target {
 
 func (self Target) String() string {
 	script := util.EscapeNL(self.Script)
-	return fmt.Sprintf("Name: \"%s\"\nScript: \"%s\"\nParser: %s", self.Name, script, self.Parser)
+	prerequisites := strings.Join(self.Prerequisites, ", ")
+
+	shellArgs := []string{}
+	for _, arg := range self.ShellArgs {
+		shellArgs = append(shellArgs, util.Quote(arg))
+	}
+	shellArgsStr := strings.Join(shellArgs, ", ")
+
+	return fmt.Sprintf(
+		"Name: \"%s\"\nNamespace: \"%s\"\nDescription: \"%s\"\nShell: \"%s\"\nShellArgs: [%s]\nCwd: \"%s\"\nPrerequisites: [%s]\nScript: \"%s\"\nParser: %s",
+		self.Name, self.Namespace, util.EscapeNL(self.Description), self.Shell, shellArgsStr, self.Cwd, prerequisites, script, self.Parser,
+	)
+}
+
+func (self *Target) ApplyDirective(name string, args string) error {
+	switch name {
+	case "shell":
+		splitedArgs := util.StringToArgs(args)
+		if len(splitedArgs) < 1 {
+			return util.NewError("too few arguments to directive @shell")
+		}
+		self.Shell = splitedArgs[0]
+		self.ShellArgs = splitedArgs[1:]
+	case "cwd":
+		cwd := strings.TrimSpace(args)
+		if cwd == "" {
+			return util.NewError("too few arguments to directive @cwd")
+		}
+		self.Cwd = cwd
+	case "env":
+		key, value, ok := strings.Cut(args, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return util.NewError("expected KEY=value in directive @env, got \"%s\"", args)
+		}
+		if self.Env == nil {
+			self.Env = make(map[string]string)
+		}
+		self.Env[key] = strings.TrimSpace(value)
+
+	default:
+		return util.NewError("invalid target directive %s", name)
+	}
+	return nil
+}
+
+func (self Target) WorkDir() string {
+	if self.Cwd == "" {
+		return self.Dir
+	}
+	if filepath.IsAbs(self.Cwd) {
+		return self.Cwd
+	}
+	return filepath.Join(self.Dir, self.Cwd)
+}
+
+func (self Target) Usage() string {
+	usage := self.Parser.Usage()
+	if usage == "" {
+		return self.Name
+	}
+	return self.Name + " " + usage
 }
+
+func (self Target) Help(programName string) string {
+	result := fmt.Sprintf("%s %s\n", programName, self.Usage())
+	if self.Description != "" {
+		result += "\n" + self.Description + "\n"
+	}
+	if len(self.Prerequisites) > 0 {
+		result += fmt.Sprintf("\nprerequisites: %s\n", strings.Join(self.Prerequisites, ", "))
+	}
+	help := self.Parser.Help()
+	if help != "" {
+		result += "\n" + help + "\n"
+	}
+	return result
+}
+
+func (self Target) LookupEnv(name string) (string, bool) {
+	value, ok := self.Env[name]
+	if ok {
+		return value, true
+	}
+	value, ok = os.LookupEnv(name)
+	if ok {
+		return value, true
+	}
+	value, ok = self.Dotenv[name]
+	return value, ok
+}
+
+func (self Target) Environ() []string {
+	result := os.Environ()
+	for key, value := range self.Dotenv {
+		_, ok := os.LookupEnv(key)
+		if !ok {
+			result = append(result, key+"="+value)
+		}
+	}
+	for key, value := range self.Env {
+		result = append(result, key+"="+value)
+	}
+	return result
+}
+
 func (self Target) Matches(name string) bool {
 	return self.Name == name
 }
+
+func (self Target) WithNamespace(namespace string) Target {
+	self.Name = namespace + NamespaceSep + self.Name
+	prerequisites := []string{}
+	for _, name := range self.Prerequisites {
+		prerequisites = append(prerequisites, namespace+NamespaceSep+name)
+	}
+	self.Prerequisites = prerequisites
+	if self.Namespace == "" {
+		self.Namespace = namespace
+	} else {
+		self.Namespace = namespace + NamespaceSep + self.Namespace
+	}
+	return self
+}
@@ -44,6 +44,21 @@ func getDebugPrefix() string {
 	return fmt.Sprintf("[DEBUG][%s]", callerInfo)
 }
 
+func Warnf(format string, v ...any) {
+	if logger == nil {
+		return
+	}
+
+	msg := fmt.Sprintf(format, v...)
+	prefix := getWarnPrefix()
+	errLogger.Printf("%s %s", prefix, msg)
+}
+
+func getWarnPrefix() string {
+	callerInfo := util.GetCallerInfo(2)
+	return fmt.Sprintf("[WARN][%s]", callerInfo)
+}
+
 func Errorf(format string, v ...any) {
 	msg := fmt.Sprintf(format, v...)
 	prefix := getErrorPrefix()
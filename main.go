@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"hund/cli"
 	"hund/hundfile"
 	"hund/logger"
 	"hund/parser"
@@ -25,13 +27,27 @@ func main() {
 		return
 	}
 
+	if options.HundfileName == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		options.HundfileName, err = parser.FindHundfile(cwd, !options.CrossGit)
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+	}
+	logger.Debugf("using Hundfile \"%s\"", options.HundfileName)
+
 	hundfileData, err := parser.ReadFile(options.HundfileName)
 	if err != nil {
 		logger.Error(err)
 		return
 	}
 
-	hundfileParser := parser.NewHundfileParser()
+	hundfileParser := parser.NewHundfileParser(options.HundfileName)
 	hundfile, err := hundfileParser.Parse(hundfileData)
 	if err != nil {
 		logger.Error(err)
@@ -40,28 +56,55 @@ func main() {
 
 	logger.Debugf("Hundfile\n%s\n", hundfile)
 
+	if options.ListTargets {
+		listing, err := hundfile.List(options.SortTargets, options.JsonOutput)
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		fmt.Println(listing)
+		return
+	}
+
 	renderer := run.NewRenderer(hundfile)
-	script, err := renderer.Render(args)
+	scripts, err := renderer.Render(args)
+	if errors.Is(err, cli.ErrHelpRequested) {
+		target, err := hundfile.GetTarget(args[0])
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		fmt.Print(target.Help(options.ProgramName))
+		return
+	}
 	if err != nil {
 		logger.Error(err)
 		return
 	}
-	logger.Debugf("Script\n%s\n", script)
+	for _, script := range scripts {
+		logger.Debugf("Script \"%s\"\n%s\n", script.Target.Name, script.Text)
+	}
 
 	if options.DryRun {
-		fmt.Println(script)
+		for _, script := range scripts {
+			fmt.Printf("# %s\n%s\n", script.Target.Name, script.Text)
+		}
 		return
 	}
 
-	executor := run.NewExecutor(options, hundfile)
+	executor := run.NewExecutor(options)
 	logger.Debugf("Executor\n%s\n", executor)
 
-	statusCode, err := executor.Exec(script)
-	if err != nil {
-		logger.Error(err)
-		return
-	}
+	for _, script := range scripts {
+		statusCode, err := executor.Exec(script)
+		if err != nil {
+			logger.Error(err)
+			return
+		}
 
-	logger.Debugf("exit status %d", statusCode)
-	os.Exit(statusCode)
+		logger.Debugf("\"%s\" exit status %d", script.Target.Name, statusCode)
+		if statusCode != 0 {
+			os.Exit(statusCode)
+		}
+	}
 }
@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"hund/util"
+	"regexp"
+	"strings"
+)
+
+var conditionExpression = regexp.MustCompile(`^(?P<not>!)?` + ANY_WHITE + `(?P<name>(` + ENV_PREFIX_EXPRESSION + `)?` + IDENTIFIER + `)(` + ANY_WHITE + `(?P<operator>==|!=)` + ANY_WHITE + `(?P<value>"[^"]*"|[^ \t"]+))?$`)
+
+var falseValues = []string{"", "0", "false"}
+
+type Condition struct {
+	Name     string
+	Negated  bool
+	Operator string
+	Value    string
+}
+
+func ParseCondition(text string) (Condition, error) {
+	text = strings.TrimSpace(text)
+	match := conditionExpression.FindStringSubmatch(text)
+	if match == nil {
+		return Condition{}, util.NewError("invalid condition \"%s\"", text)
+	}
+
+	condition := Condition{
+		Name:     match[conditionExpression.SubexpIndex("name")],
+		Negated:  match[conditionExpression.SubexpIndex("not")] != "",
+		Operator: match[conditionExpression.SubexpIndex("operator")],
+		Value:    strings.Trim(match[conditionExpression.SubexpIndex("value")], "\""),
+	}
+	if condition.Negated && condition.Operator != "" {
+		return Condition{}, util.NewError("invalid condition \"%s\", negation can't be used with comparison", text)
+	}
+	return condition, nil
+}
+
+func (self Condition) Eval(value string) bool {
+	switch self.Operator {
+	case "==":
+		return value == self.Value
+	case "!=":
+		return value != self.Value
+	}
+
+	for _, falseValue := range falseValues {
+		if value == falseValue {
+			return self.Negated
+		}
+	}
+	return !self.Negated
+}
@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"hund/util"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type Filter struct {
+	Name string
+	Args []string
+}
+
+type filterSpec struct {
+	arity int
+	apply func(values []string, args []string, shell string) []string
+}
+
+var filterSpecs = map[string]filterSpec{
+	"default":  {arity: 1, apply: applyDefault},
+	"q":        {arity: 0, apply: applyQuote},
+	"raw":      {arity: 0, apply: applyRaw},
+	"upper":    {arity: 0, apply: applyEach(strings.ToUpper)},
+	"lower":    {arity: 0, apply: applyEach(strings.ToLower)},
+	"basename": {arity: 0, apply: applyEach(filepath.Base)},
+	"replace":  {arity: 2, apply: applyReplace},
+	"join":     {arity: 1, apply: applyJoin},
+}
+
+var safeWordExpression = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+func applyDefault(values []string, args []string, shell string) []string {
+	if strings.Join(values, "") == "" {
+		return []string{args[0]}
+	}
+	return values
+}
+
+func applyEach(transform func(string) string) func([]string, []string, string) []string {
+	return func(values []string, args []string, shell string) []string {
+		result := []string{}
+		for _, value := range values {
+			result = append(result, transform(value))
+		}
+		return result
+	}
+}
+
+func applyReplace(values []string, args []string, shell string) []string {
+	return applyEach(func(value string) string {
+		return strings.ReplaceAll(value, args[0], args[1])
+	})(values, args, shell)
+}
+
+func applyJoin(values []string, args []string, shell string) []string {
+	return []string{strings.Join(values, args[0])}
+}
+
+func applyQuote(values []string, args []string, shell string) []string {
+	result := []string{}
+	for _, value := range values {
+		result = append(result, quoteWord(value, shell))
+	}
+	return result
+}
+
+func applyRaw(values []string, args []string, shell string) []string {
+	return values
+}
+
+func quoteWord(value string, shell string) string {
+	if safeWordExpression.MatchString(value) {
+		return value
+	}
+
+	switch strings.TrimSuffix(filepath.Base(shell), ".exe") {
+	case "fish":
+		value = strings.ReplaceAll(value, "\\", "\\\\")
+		return "'" + strings.ReplaceAll(value, "'", "\\'") + "'"
+	case "pwsh", "powershell":
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	case "cmd":
+		return "\"" + strings.ReplaceAll(value, "\"", "\"\"") + "\""
+	default:
+		return "'" + strings.ReplaceAll(value, "'", "'\\''") + "'"
+	}
+}
+
+func ParseFilters(text string) ([]Filter, error) {
+	result := []Filter{}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return result, nil
+	}
+
+	filterTexts, err := splitUnquoted(strings.TrimPrefix(text, "|"), '|')
+	if err != nil {
+		return result, err
+	}
+
+	for _, filterText := range filterTexts {
+		parts, err := splitUnquoted(strings.TrimSpace(filterText), ':')
+		if err != nil {
+			return result, err
+		}
+		for i := range parts {
+			parts[i] = unquote(parts[i])
+		}
+		filter := Filter{Name: parts[0], Args: parts[1:]}
+
+		spec, ok := filterSpecs[filter.Name]
+		if !ok {
+			return result, util.NewError("unknown filter \"%s\"", filter.Name)
+		}
+		if len(filter.Args) != spec.arity {
+			return result, util.NewError("filter \"%s\" expects %d arguments, got %d", filter.Name, spec.arity, len(filter.Args))
+		}
+		result = append(result, filter)
+	}
+	return result, nil
+}
+
+func splitUnquoted(text string, sep rune) ([]string, error) {
+	result := []string{}
+	var part strings.Builder
+	quoted, escaped := false, false
+
+	for _, ch := range text {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && ch == '\\':
+			escaped = true
+		case ch == '"':
+			quoted = !quoted
+		case ch == sep && !quoted:
+			result = append(result, part.String())
+			part.Reset()
+			continue
+		}
+		part.WriteRune(ch)
+	}
+	if quoted {
+		return result, util.NewError("missing closing quote in filters \"%s\"", text)
+	}
+
+	return append(result, part.String()), nil
+}
+
+func unquote(text string) string {
+	if len(text) < 2 || !strings.HasPrefix(text, "\"") || !strings.HasSuffix(text, "\"") {
+		return text
+	}
+
+	var builder strings.Builder
+	escaped := false
+	for _, ch := range text[1 : len(text)-1] {
+		if !escaped && ch == '\\' {
+			escaped = true
+			continue
+		}
+		escaped = false
+		builder.WriteRune(ch)
+	}
+	return builder.String()
+}
+
+func HasFilter(filters []Filter, name string) bool {
+	for _, filter := range filters {
+		if filter.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func ApplyFilters(values []string, filters []Filter, shell string) string {
+	return strings.Join(applyFilters(values, filters, shell), " ")
+}
+
+func ApplyAutoQuote(values []string, filters []Filter, shell string) string {
+	values = applyFilters(values, filters, shell)
+	values = slices.DeleteFunc(values, func(value string) bool { return value == "" })
+	return strings.Join(applyQuote(values, nil, shell), " ")
+}
+
+func applyFilters(values []string, filters []Filter, shell string) []string {
+	for _, filter := range filters {
+		values = filterSpecs[filter.Name].apply(values, filter.Args, shell)
+	}
+	return values
+}
@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestQuoteWord(t *testing.T) {
+	testCases := []struct {
+		value  string
+		shell  string
+		quoted string
+	}{
+		{"plain", "/bin/sh", "plain"},
+		{"a/b.txt", "/bin/bash", "a/b.txt"},
+		{"", "/bin/sh", "''"},
+		{"a b", "/bin/sh", "'a b'"},
+		{"it's", "/bin/bash", `'it'\''s'`},
+		{"$(rm -rf /)", "/bin/zsh", "'$(rm -rf /)'"},
+		{`it's \ok`, "/usr/bin/fish", `'it\'s \\ok'`},
+		{"it's", "pwsh", "'it''s'"},
+		{`say "hi"`, "cmd.exe", `"say ""hi"""`},
+	}
+
+	for _, tc := range testCases {
+		quoted := quoteWord(tc.value, tc.shell)
+		if quoted != tc.quoted {
+			t.Errorf("got %s; want %s", quoted, tc.quoted)
+		}
+	}
+}
+
+func TestApplyFilters(t *testing.T) {
+	filters, err := ParseFilters("| default:none | q")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	testCases := []struct {
+		values []string
+		result string
+	}{
+		{[]string{}, "none"},
+		{[]string{""}, "none"},
+		{[]string{"a b", "c"}, "'a b' c"},
+	}
+
+	for _, tc := range testCases {
+		result := ApplyFilters(tc.values, filters, "/bin/sh")
+		if result != tc.result {
+			t.Errorf("got %s; want %s", result, tc.result)
+		}
+	}
+}
+
+func TestApplyAutoQuote(t *testing.T) {
+	testCases := []struct {
+		values  []string
+		filters string
+		result  string
+	}{
+		{[]string{""}, "", ""},
+		{[]string{}, "", ""},
+		{[]string{"a b", "", "c"}, "", "'a b' c"},
+		{[]string{""}, "| default:\"x y\"", "'x y'"},
+	}
+
+	for _, tc := range testCases {
+		filters, err := ParseFilters(tc.filters)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		result := ApplyAutoQuote(tc.values, filters, "/bin/sh")
+		if result != tc.result {
+			t.Errorf("%q: got %s; want %s", tc.values, result, tc.result)
+		}
+	}
+
+	if result := ApplyFilters([]string{""}, []Filter{{Name: "q"}}, "/bin/sh"); result != "''" {
+		t.Errorf("explicit q: got %s; want ''", result)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	testCases := []struct {
+		filters string
+		values  []string
+		result  string
+	}{
+		{"| upper", []string{"abc"}, "ABC"},
+		{"| lower | replace:a:o", []string{"BANANA"}, "bonono"},
+		{"| basename", []string{"/a/b.txt", "c/d"}, "b.txt d"},
+		{`| join:","`, []string{"a", "b"}, "a,b"},
+		{`| join:", " | q`, []string{"a", "b"}, "'a, b'"},
+		{`| replace:":":"|"`, []string{"a:b"}, "a|b"},
+		{`| default:"a \"b\""`, []string{""}, `a "b"`},
+	}
+
+	for _, tc := range testCases {
+		filters, err := ParseFilters(tc.filters)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.filters, err)
+		}
+		result := ApplyFilters(tc.values, filters, "/bin/sh")
+		if result != tc.result {
+			t.Errorf("%s: got %s; want %s", tc.filters, result, tc.result)
+		}
+	}
+}
+
+func TestInvalidFilters(t *testing.T) {
+	texts := []string{
+		"| title",
+		"| upper:x",
+		"| replace:a",
+		"| join",
+		`| join:"`,
+	}
+
+	for _, text := range texts {
+		_, err := ParseFilters(text)
+		if err == nil {
+			t.Errorf("%s: expected error", text)
+		}
+	}
+}
@@ -1,17 +1,25 @@
 package parser
 
 import (
+	"fmt"
 	"hund/cli"
 	"hund/hundfile"
 	"hund/logger"
 	"hund/util"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 )
 
 type HundfileParser struct {
+	filename    string
+	includes    []string
+	loaded      map[string]hundfile.Hundfile
 	currentLine int
 	linesNum    int
 	globalLines []Line
+	comments    []Line
 	lines       []Line
 	targets     []*TargetParseStruct
 	hundfile    hundfile.Hundfile
@@ -21,11 +29,14 @@ type ParseFunc func(Line) (bool, error)
 type ValidateFunc func(int) error
 
 type TargetParseStruct struct {
-	name     string
-	parser   *cli.CliParser
-	startNum int
-	header   Line
-	body     []Line
+	name          string
+	description   string
+	parser        *cli.CliParser
+	prerequisites []DynamicContent
+	directives    []Line
+	startNum      int
+	header        Line
+	body          []Line
 }
 
 func NewTargetParseStruct(header Line, body []Line) (TargetParseStruct, error) {
@@ -38,9 +49,29 @@ func NewTargetParseStruct(header Line, body []Line) (TargetParseStruct, error) {
 	return result, nil
 }
 
-func NewHundfileParser() *HundfileParser {
+func (self *TargetParseStruct) script() (string, error) {
+	script := []string{}
+
+	indentation, err := self.body[0].GetIndentation()
+	if err != nil {
+		return "", err
+	}
+	for _, line := range self.body {
+		script = append(script, strings.TrimPrefix(line.text, indentation))
+	}
+	return strings.Join(script, "\n"), nil
+}
+
+func NewHundfileParser(filename string) *HundfileParser {
+	absPath, err := filepath.Abs(filename)
+	if err != nil {
+		absPath = filename
+	}
 
 	parser := HundfileParser{
+		filename:    filename,
+		includes:    []string{absPath},
+		loaded:      map[string]hundfile.Hundfile{},
 		currentLine: 0,
 		linesNum:    0,
 		lines:       nil,
@@ -51,33 +82,68 @@ func NewHundfileParser() *HundfileParser {
 }
 
 func (self *HundfileParser) Parse(lines []Line) (hundfile.Hundfile, error) {
-	logger.Debugf("parsing started")
+	logger.Debugf("parsing \"%s\" started", self.filename)
 	self.lines = lines
 	self.linesNum = len(lines)
 	self.targets = []*TargetParseStruct{}
 	self.hundfile = hundfile.NewHundfile()
+	self.hundfile.Dir = filepath.Dir(self.includes[len(self.includes)-1])
 
 	if self.linesNum == 0 {
 		return self.hundfile, util.NewError("cannot parse empty data")
 	}
 
 	err := self.validate([]ValidateFunc{
+		self.joinContinuations,
 		self.extractGlobals,
 		self.applyGlobals,
 		self.splitTargets,
 		self.parseHeaders,
+		self.extractTargetDirectives,
 		self.clearEmptyPreAndPost,
 		self.checkEmptyBodies,
 		self.checkIndentation,
+		self.checkTemplates,
 		self.checkVariables,
 		self.checkCalls,
 		self.checkEmbedCalls,
+		self.checkPrerequisites,
 		self.addTargets,
 	})
 
+	if err != nil {
+		err = util.NewError("%s: %w", self.filename, err)
+	}
+
 	return self.hundfile, err
 }
 
+func (self *HundfileParser) joinContinuations(phase int) error {
+	logger.Debugf("phase %d: joining escaped newlines", phase)
+	result := []Line{}
+	for i := 0; i < len(self.lines); i++ {
+		line := self.lines[i]
+		if line.IsIndented() || line.IsComment() {
+			result = append(result, line)
+			continue
+		}
+
+		for line.IsNewlineEscaped() {
+			if i+1 >= len(self.lines) {
+				return util.NewError("line %d: escaped newline at the end of file", line.num)
+			}
+			i += 1
+			logger.Debugf("line %d: joining line %d", line.num, self.lines[i].num)
+			line = line.Join(self.lines[i])
+		}
+		result = append(result, line)
+	}
+
+	self.lines = result
+	self.linesNum = len(result)
+	return nil
+}
+
 func (self *HundfileParser) extractGlobals(phase int) error {
 	logger.Debugf("phase %d: extracting global declarations", phase)
 	f := func(line Line) (bool, error) {
@@ -109,18 +175,112 @@ func (self *HundfileParser) applyGlobals(phase int) error {
 
 		logger.Debugf("line %d: extracted global \"%s\" with args \"%s\"", line.num, globalName, globalArgs)
 
+		if globalName == "include" {
+			err = self.include(line, globalArgs)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		err = self.hundfile.ApplyGlobal(globalName, globalArgs)
 		if err != nil {
-			return err
+			return util.NewError("line %d: %w", line.num, err)
 		}
 	}
 	return nil
 }
 
+func (self *HundfileParser) include(line Line, args string) error {
+	includeArgs := strings.Split(args, ",")
+	path := strings.TrimSpace(includeArgs[0])
+	if path == "" {
+		return util.NewError("line %d: missing path in @include directive", line.num)
+	}
+
+	namespace := ""
+	for _, arg := range includeArgs[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(arg), "=")
+		if key != "as" {
+			return util.NewError("line %d: invalid @include argument \"%s\"", line.num, arg)
+		}
+		if !namespaceExpression.MatchString(value) {
+			return util.NewError("line %d: invalid namespace \"%s\"", line.num, value)
+		}
+		namespace = value
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(self.filename), path)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return util.NewError("line %d: can't resolve include path \"%s\": %w", line.num, path, err)
+	}
+
+	for _, included := range self.includes {
+		if included == absPath {
+			circle := strings.Join(self.includes, " -> ")
+			circle += fmt.Sprintf(" -> %s", absPath)
+			return util.NewError("line %d: detected circular include %s", line.num, circle)
+		}
+	}
+
+	included, err := self.load(line, path, absPath)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range included.Targets {
+		if namespace != "" {
+			target = target.WithNamespace(namespace)
+		}
+		if self.isLoaded(target) {
+			continue
+		}
+		err = self.hundfile.AddTarget(target)
+		if err != nil {
+			return util.NewError("line %d: %w", line.num, err)
+		}
+	}
+	logger.Debugf("line %d: included %d targets from \"%s\"", line.num, len(included.Targets), path)
+	return nil
+}
+
+func (self *HundfileParser) load(line Line, path string, absPath string) (hundfile.Hundfile, error) {
+	if included, ok := self.loaded[absPath]; ok {
+		logger.Debugf("line %d: \"%s\" already loaded", line.num, path)
+		return included, nil
+	}
+
+	logger.Debugf("line %d: including \"%s\"", line.num, path)
+	lines, err := ReadFile(path)
+	if err != nil {
+		return hundfile.Hundfile{}, util.NewError("line %d: can't include \"%s\": %w", line.num, path, err)
+	}
+
+	includeParser := NewHundfileParser(path)
+	includeParser.includes = append(slices.Clone(self.includes), absPath)
+	includeParser.loaded = self.loaded
+	included, err := includeParser.Parse(lines)
+	if err != nil {
+		return included, util.NewError("line %d: %w", line.num, err)
+	}
+	self.loaded[absPath] = included
+	return included, nil
+}
+
+func (self *HundfileParser) isLoaded(target hundfile.Target) bool {
+	existing, err := self.hundfile.GetTarget(target.Name)
+	return err == nil && existing.Name == target.Name && existing.Source == target.Source
+}
+
 func (self *HundfileParser) splitTargets(phase int) error {
 	logger.Debugf("phase %d: splitting targets", phase)
 	for self.currentLine < len(self.lines) {
 		var header Line
+		description := ""
 		body := []Line{}
 		targetHeaderSet := false
 		f := func(line Line) (bool, error) {
@@ -131,6 +291,7 @@ func (self *HundfileParser) splitTargets(phase int) error {
 				}
 				logger.Debugf("line %d: target header", line.num)
 				header = line
+				description = self.getDescription()
 				targetHeaderSet = true
 				return true, nil
 			}
@@ -151,6 +312,8 @@ func (self *HundfileParser) splitTargets(phase int) error {
 		if err != nil {
 			return err
 		}
+		targetStruct.parser.SetBaseDir(filepath.Dir(self.filename))
+		targetStruct.description = description
 		self.targets = append(self.targets, &targetStruct)
 	}
 	return nil
@@ -167,6 +330,27 @@ func (self *HundfileParser) parseHeaders(phase int) error {
 	return nil
 }
 
+func (self *HundfileParser) extractTargetDirectives(phase int) error {
+	logger.Debugf("phase %d: extracting target directives", phase)
+	for _, targetRepr := range self.targets {
+		body := targetRepr.body
+		for len(body) > 0 {
+			line := body[0]
+			if !line.IsEmpty() && !line.IsTargetDirective() {
+				break
+			}
+			if !line.IsEmpty() {
+				logger.Debugf("line %d: target directive for \"%s\"", line.num, targetRepr.name)
+				directive := Line{text: strings.TrimSpace(line.text), num: line.num}
+				targetRepr.directives = append(targetRepr.directives, directive)
+			}
+			body = body[1:]
+		}
+		targetRepr.body = body
+	}
+	return nil
+}
+
 func (self *HundfileParser) checkNameUniquness(phase int) error {
 	logger.Debugf("phase %d: checking target name uniquness", phase)
 	return nil
@@ -236,6 +420,21 @@ func (self *HundfileParser) checkIndentation(phase int) error {
 	return nil
 }
 
+func (self *HundfileParser) checkTemplates(phase int) error {
+	logger.Debugf("phase %d: checking templates", phase)
+	for _, target := range self.targets {
+		script, err := target.script()
+		if err != nil {
+			return err
+		}
+		_, index, err := parseTemplate(script)
+		if err != nil {
+			return util.NewError("line %d: %w", target.body[index].num, err)
+		}
+	}
+	return nil
+}
+
 func (self *HundfileParser) checkVariables(phase int) error {
 	logger.Debugf("phase %d: checking proper variables usage", phase)
 	for _, target := range self.targets {
@@ -243,7 +442,45 @@ func (self *HundfileParser) checkVariables(phase int) error {
 		body := target.body
 		parser := target.parser
 		targetVariables := make(map[string]bool)
+		loopVariables := []string{}
 		for _, line := range body {
+			name, args, ok := line.GetBlockDirective()
+			switch {
+			case ok && name == "if":
+				loopVariables = append(loopVariables, "")
+			case ok && name == "end":
+				loopVariables = loopVariables[:len(loopVariables)-1]
+			case ok && name == "for":
+				variable, list, err := ParseLoop(args)
+				if err != nil {
+					return util.NewError("line %d: %w", line.num, err)
+				}
+				targetVariables[list] = true
+
+				if !parser.IsList(list) {
+					return util.NewError("line %d: variable \"%s\" is not a list", line.num, list)
+				}
+				if parser.Contains(variable) || self.hundfile.HasVariable(variable) || slices.Contains(loopVariables, variable) {
+					return util.NewError("line %d: loop variable \"%s\" shadows another variable", line.num, variable)
+				}
+				loopVariables = append(loopVariables, variable)
+			}
+
+			if ok && (name == "if" || name == "elif") {
+				condition, err := ParseCondition(args)
+				if err != nil {
+					return util.NewError("line %d: %w", line.num, err)
+				}
+				targetVariables[condition.Name] = true
+
+				_, isEnv := strings.CutPrefix(condition.Name, ENV_PREFIX)
+				defined := parser.Contains(condition.Name) || self.hundfile.HasVariable(condition.Name) || condition.Name == hundfile.INVOCATION_DIR
+				defined = defined || slices.Contains(loopVariables, condition.Name)
+				if !isEnv && !defined {
+					return util.NewError("line %d: undefined variable \"%s\" in condition", line.num, condition.Name)
+				}
+			}
+
 			variables := line.GetVariables()
 			if len(variables) == 0 {
 				logger.Debugf("line %d: no variables found", line.num)
@@ -253,7 +490,28 @@ func (self *HundfileParser) checkVariables(phase int) error {
 
 			for _, v := range variables {
 				targetVariables[v.text] = true
-				if !parser.Contains(v.text) {
+				filters, err := ParseFilters(v.filters)
+				if err != nil {
+					return util.NewError("line %d, col %d: %w", line.num, v.col, err)
+				}
+
+				if v.index != "" && !parser.IsList(v.text) {
+					return util.NewError("line %d, col %d: variable \"%s\" is not a list", line.num, v.col, v.text)
+				}
+
+				envName, isEnv := strings.CutPrefix(v.text, ENV_PREFIX)
+				if isEnv {
+					if !self.isEnvSet(target, envName) && !HasFilter(filters, "default") {
+						logger.Warnf("%s: line %d, col %d: environment variable \"%s\" is not set\n", self.filename, line.num, v.col, envName)
+					}
+					continue
+				}
+
+				if slices.Contains(loopVariables, v.text) {
+					continue
+				}
+
+				if !parser.Contains(v.text) && !self.hundfile.HasVariable(v.text) && v.text != hundfile.INVOCATION_DIR {
 					return util.NewError("line %d, col %d: undefined variable \"%s\"", line.num, v.col, v.text)
 				}
 			}
@@ -269,6 +527,23 @@ func (self *HundfileParser) checkVariables(phase int) error {
 	return nil
 }
 
+func (self *HundfileParser) isEnvSet(target *TargetParseStruct, name string) bool {
+	if _, ok := os.LookupEnv(name); ok {
+		return true
+	}
+	if _, ok := self.hundfile.Env[name]; ok {
+		return true
+	}
+	for _, directive := range target.directives {
+		directiveName, _ := directive.GetGlobalName()
+		key, _, _ := strings.Cut(directive.GetGlobalArgs(), "=")
+		if directiveName == "env" && strings.TrimSpace(key) == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (self *HundfileParser) checkCalls(phase int) error {
 	logger.Debugf("phase %d: checking target calls", phase)
 	for _, target := range self.targets {
@@ -289,7 +564,7 @@ func (self *HundfileParser) checkCalls(phase int) error {
 				return util.NewError("line %d, col %d: non-embed call in embed context", line.num, call.col)
 			}
 
-			args := util.StringToArgs(call.text)
+			args := SplitCallArgs(call.text)
 			logger.Debugf("line %d: detected call %v", line.num, args)
 
 			if len(args) == 0 {
@@ -301,17 +576,11 @@ func (self *HundfileParser) checkCalls(phase int) error {
 
 			logger.Debugf("line %d: looking for target \"%s\"", line.num, targetName)
 
-			var foundTarget *TargetParseStruct
-			for _, target := range self.targets {
-				if target.name == targetName {
-					foundTarget = target
-				}
-			}
-
-			if foundTarget == nil {
+			targetParser, ok := self.findParser(targetName)
+			if !ok {
 				return util.NewError("line %d, col %d: couldn't find target \"%s\"", line.num, call.col, targetName)
 			}
-			args, err := foundTarget.parser.Parse(args, cli.NewDummyWriter())
+			args, err := targetParser.Check(args, ContainsVariables)
 			if err != nil {
 				return util.NewError("line %d: invalid target call arguments %w", line.num, err)
 			}
@@ -337,7 +606,7 @@ func (self *HundfileParser) checkEmbedCalls(phase int) error {
 			}
 			logger.Debugf("line %d: found %d calls", line.num, len(calls))
 			for _, call := range calls {
-				args := util.StringToArgs(call.text)
+				args := SplitCallArgs(call.text)
 				logger.Debugf("line %d: detected call %v", line.num, args)
 
 				if len(args) == 0 {
@@ -348,17 +617,11 @@ func (self *HundfileParser) checkEmbedCalls(phase int) error {
 				args = args[1:]
 				logger.Debugf("line %d: looking for target \"%s\"", line.num, targetName)
 
-				var foundTarget *TargetParseStruct
-				for _, target := range self.targets {
-					if target.name == targetName {
-						foundTarget = target
-					}
-				}
-
-				if foundTarget == nil {
+				targetParser, ok := self.findParser(targetName)
+				if !ok {
 					return util.NewError("line %d, col %d: couldn't find target \"%s\"", line.num, call.col, targetName)
 				}
-				args, err := foundTarget.parser.Parse(args, cli.NewDummyWriter())
+				args, err := targetParser.Check(args, ContainsVariables)
 				if err != nil {
 					return util.NewError("line %d: invalid target call arguments %w", line.num, err)
 				}
@@ -372,28 +635,81 @@ func (self *HundfileParser) checkEmbedCalls(phase int) error {
 	return nil
 }
 
+func (self *HundfileParser) checkPrerequisites(phase int) error {
+	logger.Debugf("phase %d: checking target prerequisites", phase)
+	for _, target := range self.targets {
+		logger.Debugf("target \"%s\": checking %d prerequisites", target.name, len(target.prerequisites))
+		for _, prerequisite := range target.prerequisites {
+			targetParser, ok := self.findParser(prerequisite.text)
+			if !ok {
+				return util.NewError("%s: couldn't find target \"%s\"", target.header.Pos(prerequisite.col), prerequisite.text)
+			}
+
+			_, err := targetParser.Check([]string{}, ContainsVariables)
+			if err != nil {
+				return util.NewError("%s: prerequisite \"%s\" can't be run without arguments %w", target.header.Pos(prerequisite.col), prerequisite.text, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (self *HundfileParser) findParser(targetName string) (*cli.CliParser, bool) {
+	for _, target := range self.targets {
+		if target.name == targetName {
+			return target.parser, true
+		}
+	}
+
+	target, err := self.hundfile.GetTarget(targetName)
+	if err != nil {
+		return nil, false
+	}
+	return target.Parser, true
+}
+
 func (self *HundfileParser) addTargets(phase int) error {
 	logger.Debugf("phase %d: adding targets to hundfile", phase)
 	for _, targetSpec := range self.targets {
 		target := hundfile.Target{}
 		target.Name = targetSpec.name
+		target.Description = targetSpec.description
 		target.Parser = targetSpec.parser
+		target.Variables = self.hundfile.Variables
+		target.Dotenv = self.hundfile.Env
+		target.Shell = self.hundfile.Shell
+		target.ShellArgs = self.hundfile.ShellArgs
+		target.AutoQuote = self.hundfile.AutoQuote
+		target.FlagValue = self.hundfile.FlagValue
+		target.EmbedSep = self.hundfile.EmbedSep
+		target.Dir = self.hundfile.Dir
+		target.Cwd = self.hundfile.Cwd
+		target.Source = self.includes[len(self.includes)-1]
+
+		for _, directive := range targetSpec.directives {
+			name, err := directive.GetGlobalName()
+			if err != nil {
+				return err
+			}
+			err = target.ApplyDirective(name, directive.GetGlobalArgs())
+			if err != nil {
+				return util.NewError("line %d: %w", directive.num, err)
+			}
+		}
+		for _, prerequisite := range targetSpec.prerequisites {
+			target.Prerequisites = append(target.Prerequisites, prerequisite.text)
+		}
 
-		script := []string{}
-
-		firstLine := targetSpec.body[0]
-		indentation, err := firstLine.GetIndentation()
+		script, err := targetSpec.script()
 		if err != nil {
 			return err
 		}
-		for _, line := range targetSpec.body {
-			text := line.text
-			text = strings.TrimPrefix(text, indentation)
-			script = append(script, text)
-		}
-		target.Script = strings.Join(script, "\n")
+		target.Script = script
 
-		self.hundfile.AddTarget(target)
+		err = self.hundfile.AddTarget(target)
+		if err != nil {
+			return util.NewError("line %d: %w", targetSpec.startNum, err)
+		}
 	}
 	return nil
 }
@@ -405,7 +721,7 @@ func (self *HundfileParser) parseHeader(targetRepr *TargetParseStruct) error {
 
 	name := line.Extract(headerTargetName)
 	if name == "" {
-		return util.NewError("line %d, col %d: can't extract target name", header.num, line.col)
+		return util.NewError("%s: can't extract target name", header.Pos(line.col))
 	}
 
 	logger.Debugf("line %d: extracted target name \"%s\"", header.num, name)
@@ -418,16 +734,17 @@ func (self *HundfileParser) parseHeader(targetRepr *TargetParseStruct) error {
 		expectNext := false
 		for {
 			line.SkipSpaces()
+			col := line.col
 			argument := line.Extract(headerArgumentDefinition)
 			if argument == "" {
 				if expectNext {
-					return util.NewError("line %d, col %d: expected argument definition", header.num, line.col)
+					return util.NewError("%s: expected argument definition", header.Pos(line.col))
 				}
 				break
 			}
 			err := targetRepr.parser.Add(argument)
 			if err != nil {
-				return err
+				return util.NewError("%s: %w", header.Pos(col), err)
 			}
 			line.SkipSpaces()
 			expectNext = line.Trim(",")
@@ -435,28 +752,55 @@ func (self *HundfileParser) parseHeader(targetRepr *TargetParseStruct) error {
 
 		ok = line.Trim(")")
 		if !ok {
-			return util.NewError("line %d, col %d: expected ')'", header.num, line.col)
+			return util.NewError("%s: expected ')'", header.Pos(line.col))
 		}
 	}
 
 	ok = line.Trim(":")
 	if !ok {
-		return util.NewError("line %d, col %d: expected ':'", header.num, line.col)
+		return util.NewError("%s: expected ':'", header.Pos(line.col))
 	}
 
 	for !line.Finished() {
 		line.SkipSpaces()
+		if line.Trim("<-") {
+			logger.Debugf("line %d: detected prerequisites list", header.num)
+			return self.parsePrerequisites(targetRepr, line)
+		}
+
+		col := line.col
 		option := line.Extract(headerOptionDefinition)
 		if option == "" {
-			return util.NewError("line %d, col %d: expected option definition", header.num, line.col)
+			return util.NewError("%s: expected option definition", header.Pos(line.col))
 		}
 
 		err := targetRepr.parser.Add(option)
 		if err != nil {
-			return err
+			return util.NewError("%s: %w", header.Pos(col), err)
+		}
+	}
+
+	return nil
+}
+
+func (self *HundfileParser) parsePrerequisites(targetRepr *TargetParseStruct, line *EditableLine) error {
+	header := targetRepr.header
+	line.SkipSpaces()
+	for !line.Finished() {
+		col := line.col
+		name := line.Extract(headerPrerequisite)
+		if name == "" {
+			return util.NewError("%s: expected prerequisite name", header.Pos(line.col))
 		}
+
+		logger.Debugf("line %d: extracted prerequisite \"%s\"", header.num, name)
+		targetRepr.prerequisites = append(targetRepr.prerequisites, DynamicContent{col: col, text: name})
+		line.SkipSpaces()
 	}
 
+	if len(targetRepr.prerequisites) == 0 {
+		return util.NewError("%s: expected prerequisite name", header.Pos(line.col))
+	}
 	return nil
 }
 
@@ -465,6 +809,11 @@ func (self *HundfileParser) apply(parseFunc ParseFunc) error {
 		line := self.lines[self.currentLine]
 		if line.IsComment() {
 			logger.Debugf("line %d: comment, removed", line.num)
+			if line.IsIndented() {
+				self.comments = nil
+			} else {
+				self.comments = append(self.comments, line)
+			}
 			self.currentLine += 1
 			continue
 		}
@@ -475,11 +824,20 @@ func (self *HundfileParser) apply(parseFunc ParseFunc) error {
 		if !ok {
 			break
 		}
+		self.comments = nil
 		self.currentLine += 1
 	}
 	return nil
 }
 
+func (self *HundfileParser) getDescription() string {
+	description := []string{}
+	for _, comment := range self.comments {
+		description = append(description, comment.GetCommentText())
+	}
+	return strings.Join(description, "\n")
+}
+
 func (self *HundfileParser) validate(funcs []ValidateFunc) error {
 	for i, f := range funcs {
 		err := f(i + 1)
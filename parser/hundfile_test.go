@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func parseTestHundfile(text string) error {
+	lines := []Line{}
+	for i, line := range strings.Split(text, "\n") {
+		lines = append(lines, Line{text: line, num: i + 1})
+	}
+	_, err := NewHundfileParser("Hundfile").Parse(lines)
+	return err
+}
+
+func TestPrerequisiteErrors(t *testing.T) {
+	testCases := []struct {
+		text     string
+		position string
+	}{
+		{"build: <- missing\n    make", "line 1, col 11"},
+		{"build: \\\n    a|a=flag \\\n    <- \\\n     missing\n    make @{{a}}", "line 4, col 6"},
+		{"test(x):\n    echo @{{x}}\n\nbuild: \\\n  <- test\n    make", "line 5, col 6"},
+	}
+
+	for _, tc := range testCases {
+		err := parseTestHundfile(tc.text)
+		if err == nil {
+			t.Errorf("%q: expected error", tc.text)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.position) {
+			t.Errorf("got \"%s\"; want position %s", err, tc.position)
+		}
+	}
+}
+
+func TestDiamondInclude(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Hundfile": "@include(a)\n@include(b)\n\nall: <- lint\n    @(( a ))\n    @(( b ))\n",
+		"a":        "@include(common)\n\na:\n    @(( lint ))\n",
+		"b":        "@include(common)\n\nb:\n    @(( lint ))\n",
+		"common":   "lint:\n    go vet\n",
+	}
+	for name, text := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	filename := filepath.Join(dir, "Hundfile")
+	lines, err := ReadFile(filename)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	result, err := NewHundfileParser(filename).Parse(lines)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(result.Targets) != 4 {
+		t.Errorf("got %d targets; want 4", len(result.Targets))
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	testCases := []struct {
+		text     string
+		position string
+	}{
+		{"build(fast?):\n    @if fast\n    make", "line 2:"},
+		{"build:\n    make\n    @end", "line 3:"},
+		{"build(a*):\n    @for x in a\n        @if x ==\n        @end\n    @end", "line 3:"},
+		{"build(a?):\n    @if a\n    @else\n    @elif a\n    @end", "line 4:"},
+	}
+
+	for _, tc := range testCases {
+		err := parseTestHundfile(tc.text)
+		if err == nil {
+			t.Errorf("%q: expected error", tc.text)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.position) {
+			t.Errorf("got \"%s\"; want position %s", err, tc.position)
+		}
+	}
+}
+
+func TestBuiltinVariableNames(t *testing.T) {
+	texts := []string{
+		"@var(invocation_dir=/tmp)\n\nbuild:\n    echo @{{invocation_dir}}",
+		"build(files*):\n    @for invocation_dir in files\n        echo @{{invocation_dir}}\n    @end",
+	}
+
+	for _, text := range texts {
+		err := parseTestHundfile(text)
+		if err == nil || !strings.Contains(err.Error(), "built-in variable") {
+			t.Errorf("%q: got %v; want built-in variable error", text, err)
+		}
+	}
+}
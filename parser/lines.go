@@ -2,10 +2,12 @@ package parser
 
 import (
 	"bufio"
+	"fmt"
 	"hund/logger"
 	"hund/util"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -13,33 +15,87 @@ const IDENTIFIER = `([a-zA-Z][a-zA-Z0-9_-]*)`
 const ARGS_AND_OPTIONS = `((\(.*\))?:.*)`
 const OPEN_PARENT_WHITE = `(\([ \t]*\\)`
 const ANY_WHITE = `[ \t]*`
+const BLOCK_DIRECTIVES = `if|elif|else|end|for`
 const CALL_START = `@\(\(`
 const CALL_END = `\)\)`
 const EMBED_CALL_START = `@\[\[`
 const EMBED_CALL_END = `\]\]`
+const VALUE_TYPE = `(:[a-z]+)?`
+const CHOICES = `(\[[^\]]*\])?`
+const DEFAULT_VALUE = `(\([^)]*\))?`
+const ENV_PREFIX = "env."
+const ENV_PREFIX_EXPRESSION = `env\.`
 
 var targetDefinitionPattern = regexp.MustCompile(`^` + IDENTIFIER + ARGS_AND_OPTIONS)
 var escapedNewlineExpression = regexp.MustCompile(`^.*\\$`)
+var targetDirectiveExpression = regexp.MustCompile(`^[ \t]+@` + IDENTIFIER + `\(.*\)[ \t]*$`)
 var commentExpression = regexp.MustCompile(`^[ \t]*//.*$`)
 var indentedExpression = regexp.MustCompile(`^((  )|\t).*$`)
 
 var headerTargetName = regexp.MustCompile(`^` + IDENTIFIER)
-var headerOptionDefinition = regexp.MustCompile(`^` + IDENTIFIER + `(\|[a-zA-Z0-9])?=(value|flag)`)
-var headerArgumentDefinition = regexp.MustCompile(`^` + IDENTIFIER + `[\+\?\*]?`)
+var namespaceExpression = regexp.MustCompile(`^` + IDENTIFIER + `$`)
+var headerOptionDefinition = regexp.MustCompile(`^` + IDENTIFIER + `(\|[a-zA-Z0-9])?=(value|flag|list|count)` + VALUE_TYPE + CHOICES + DEFAULT_VALUE)
+var headerArgumentDefinition = regexp.MustCompile(`^` + IDENTIFIER + `[\+\?\*]?` + VALUE_TYPE + CHOICES + DEFAULT_VALUE)
+var headerPrerequisite = regexp.MustCompile(`^` + IDENTIFIER + `(:` + IDENTIFIER + `)*`)
 
-var variableNameExtractor = regexp.MustCompile(`@{{( )*(?P<name>` + IDENTIFIER + `)( )*}}`)
+var variableNameExtractor = regexp.MustCompile(`@{{( )*(?P<name>(` + ENV_PREFIX_EXPRESSION + `)?` + IDENTIFIER + `)(\[(?P<index>[0-9]+)\])?( )*(?P<filters>(\|("([^"\\]|\\.)*"|[^}"])*)?)}}`)
 var callOnlyExpression = regexp.MustCompile(
 	`^` + ANY_WHITE + CALL_START + ANY_WHITE + `([a-zA-Z].*)` + CALL_END + ANY_WHITE + `$`,
 )
 var targetCallExtractor = regexp.MustCompile(CALL_START + ANY_WHITE + `(?P<name>[a-zA-Z].*?)` + ANY_WHITE + CALL_END)
 var targetEmbedCallExtractor = regexp.MustCompile(EMBED_CALL_START + ANY_WHITE + `(?P<name>[a-zA-Z].*?)` + ANY_WHITE + EMBED_CALL_END)
 
+var blockDirectiveExtractor = regexp.MustCompile(`^` + ANY_WHITE + `@(?P<name>` + BLOCK_DIRECTIVES + `)([ \t]+(?P<args>.*?))?` + ANY_WHITE + `$`)
+var escapedDirectiveExpression = regexp.MustCompile(`^` + ANY_WHITE + ESCAPE + `@(` + BLOCK_DIRECTIVES + `)([ \t]|$)`)
+
 var globalNameExtractor = regexp.MustCompile(`^@(?P<name>` + IDENTIFIER + `).*`)
 var globalArgsExtractor = regexp.MustCompile(`\((?P<args>.*)\)`)
 
 type Line struct {
-	text string
-	num  int
+	text     string
+	num      int
+	segments []lineSegment
+}
+
+type lineSegment struct {
+	col     int
+	num     int
+	origCol int
+}
+
+func (self Line) Join(next Line) Line {
+	segments := self.segments
+	if segments == nil {
+		segments = []lineSegment{{col: 1, num: self.num, origCol: 1}}
+	}
+
+	text := strings.TrimSuffix(self.text, "\\")
+	if !strings.HasSuffix(text, " ") {
+		text += " "
+	}
+	nextText := strings.TrimLeft(next.text, " \t")
+	origCol := len(next.text) - len(nextText) + 1
+
+	segment := lineSegment{col: len(text) + 1, num: next.num, origCol: origCol}
+	segments = append(slices.Clone(segments), segment)
+	return Line{text: text + nextText, num: self.num, segments: segments}
+}
+
+func (self Line) Position(col int) (int, int) {
+	num, origCol := self.num, col
+	for _, segment := range self.segments {
+		if segment.col > col {
+			break
+		}
+		num = segment.num
+		origCol = col - segment.col + segment.origCol
+	}
+	return num, origCol
+}
+
+func (self Line) Pos(col int) string {
+	num, origCol := self.Position(col)
+	return fmt.Sprintf("line %d, col %d", num, origCol)
 }
 
 func (self Line) IsTargetHeader() bool {
@@ -54,10 +110,20 @@ func (self Line) IsNewlineEscaped() bool {
 	return self.matches(escapedNewlineExpression)
 }
 
+func (self Line) IsTargetDirective() bool {
+	return self.matches(targetDirectiveExpression)
+}
+
 func (self Line) IsComment() bool {
 	return self.matches(commentExpression)
 }
 
+func (self Line) GetCommentText() string {
+	text := strings.TrimSpace(self.text)
+	text = strings.TrimPrefix(text, "//")
+	return strings.TrimPrefix(text, " ")
+}
+
 func (self Line) IsEmpty() bool {
 	return strings.TrimSpace(self.text) == ""
 }
@@ -116,27 +182,32 @@ func (self Line) HasIndentation(indentation string) bool {
 }
 
 type DynamicContent struct {
-	col  int
-	text string
+	col     int
+	text    string
+	index   string
+	filters string
 }
 
 func (self Line) GetVariables() []DynamicContent {
 	result := []DynamicContent{}
 
-	indexMatches := variableNameExtractor.FindAllStringIndex(self.text, -1)
+	text := maskEscapes(self.text)
+	indexMatches := variableNameExtractor.FindAllStringIndex(text, -1)
 	if indexMatches == nil {
 		return result
 	}
 
-	nameMatches := variableNameExtractor.FindAllStringSubmatch(self.text, -1)
+	nameMatches := variableNameExtractor.FindAllStringSubmatch(text, -1)
 	if nameMatches == nil {
 		return result
 	}
 
 	for i, match := range nameMatches {
 		variable := match[variableNameExtractor.SubexpIndex("name")]
+		index := match[variableNameExtractor.SubexpIndex("index")]
+		filters := match[variableNameExtractor.SubexpIndex("filters")]
 		col := indexMatches[i][0]
-		result = append(result, DynamicContent{col: col, text: variable})
+		result = append(result, DynamicContent{col: col, text: variable, index: index, filters: filters})
 	}
 
 	return result
@@ -145,62 +216,24 @@ func (self Line) GetVariables() []DynamicContent {
 type RendererRepr struct {
 	Name     string
 	InScript string
+	Index    string
+	Filters  string
 }
 
-func GetVariables(script string) []RendererRepr {
-	result := []RendererRepr{}
-
-	matches := variableNameExtractor.FindAllStringSubmatch(script, -1)
-	if matches == nil {
-		return result
-	}
-	for _, match := range matches {
-		inScript := match[0]
-		name := match[variableNameExtractor.SubexpIndex("name")]
-		result = append(result, RendererRepr{Name: name, InScript: inScript})
-	}
-	return result
-}
-
-func GetCalls(script string) []RendererRepr {
-	result := []RendererRepr{}
-
-	matches := targetCallExtractor.FindAllStringSubmatch(script, -1)
-	if matches == nil {
-		return result
-	}
-	for _, match := range matches {
-		inScript := match[0]
-		name := match[targetCallExtractor.SubexpIndex("name")]
-		result = append(result, RendererRepr{Name: name, InScript: inScript})
-	}
-	return result
-}
-
-func GetEmbeds(script string) []RendererRepr {
-	result := []RendererRepr{}
-
-	matches := targetEmbedCallExtractor.FindAllStringSubmatch(script, -1)
-	if matches == nil {
-		return result
-	}
-	for _, match := range matches {
-		inScript := match[0]
-		name := match[targetEmbedCallExtractor.SubexpIndex("name")]
-		result = append(result, RendererRepr{Name: name, InScript: inScript})
-	}
-	return result
+func ContainsVariables(text string) bool {
+	return variableNameExtractor.MatchString(maskEscapes(text))
 }
 
 func (self Line) GetCalls() []DynamicContent {
 	result := []DynamicContent{}
 
-	indexMatches := targetCallExtractor.FindAllStringIndex(self.text, -1)
+	text := maskEscapes(self.text)
+	indexMatches := targetCallExtractor.FindAllStringIndex(text, -1)
 	if indexMatches == nil {
 		return result
 	}
 
-	nameMatches := targetCallExtractor.FindAllStringSubmatch(self.text, -1)
+	nameMatches := targetCallExtractor.FindAllStringSubmatch(text, -1)
 	if nameMatches == nil {
 		return result
 	}
@@ -217,12 +250,13 @@ func (self Line) GetCalls() []DynamicContent {
 func (self Line) GetEmbedCalls() []DynamicContent {
 	result := []DynamicContent{}
 
-	indexMatches := targetEmbedCallExtractor.FindAllStringIndex(self.text, -1)
+	text := maskEscapes(self.text)
+	indexMatches := targetEmbedCallExtractor.FindAllStringIndex(text, -1)
 	if indexMatches == nil {
 		return result
 	}
 
-	nameMatches := targetEmbedCallExtractor.FindAllStringSubmatch(self.text, -1)
+	nameMatches := targetEmbedCallExtractor.FindAllStringSubmatch(text, -1)
 	if nameMatches == nil {
 		return result
 	}
@@ -236,8 +270,29 @@ func (self Line) GetEmbedCalls() []DynamicContent {
 	return result
 }
 
+func (self Line) GetBlockDirective() (string, string, bool) {
+	return getBlockDirective(self.text)
+}
+
+func unescapeBlockDirective(text string) string {
+	if !escapedDirectiveExpression.MatchString(strings.TrimSuffix(text, "\n")) {
+		return text
+	}
+	return strings.Replace(text, ESCAPE, "", 1)
+}
+
+func getBlockDirective(text string) (string, string, bool) {
+	match := blockDirectiveExtractor.FindStringSubmatch(strings.TrimSuffix(text, "\n"))
+	if match == nil {
+		return "", "", false
+	}
+	name := match[blockDirectiveExtractor.SubexpIndex("name")]
+	args := match[blockDirectiveExtractor.SubexpIndex("args")]
+	return name, args, true
+}
+
 func (self Line) IsCallOnly() bool {
-	return self.matches(callOnlyExpression)
+	return callOnlyExpression.MatchString(maskEscapes(self.text))
 }
 
 func (self Line) matches(expression *regexp.Regexp) bool {
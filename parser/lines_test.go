@@ -73,3 +73,51 @@ func TestHeader(t *testing.T) {
 		}
 	}
 }
+
+func TestCommentText(t *testing.T) {
+	testCases := []struct {
+		text    string
+		comment string
+	}{
+		{"// a comment", "a comment"},
+		{"//no space", "no space"},
+		{"//   indented text", "  indented text"},
+		{"//", ""},
+	}
+
+	for _, tc := range testCases {
+		line := Line{text: tc.text, num: 0}
+		if line.GetCommentText() != tc.comment {
+			t.Errorf("got \"%s\"; want \"%s\"", line.GetCommentText(), tc.comment)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	first := Line{text: "deploy(env): \\", num: 3}
+	second := Line{text: "    port|p=value \\", num: 4}
+	third := Line{text: "  <- build", num: 5}
+	line := first.Join(second).Join(third)
+
+	if line.text != "deploy(env): port|p=value <- build" {
+		t.Errorf("got \"%s\"", line.text)
+	}
+
+	testCases := []struct {
+		col int
+		num int
+		pos int
+	}{
+		{1, 3, 1},
+		{14, 4, 5},
+		{27, 5, 3},
+		{30, 5, 6},
+	}
+
+	for _, tc := range testCases {
+		num, pos := line.Position(tc.col)
+		if num != tc.num || pos != tc.pos {
+			t.Errorf("col %d: got line %d, col %d; want line %d, col %d", tc.col, num, pos, tc.num, tc.pos)
+		}
+	}
+}
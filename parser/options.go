@@ -24,6 +24,10 @@ func ParseOptions(args []string, target *hundfile.Options) ([]string, error) {
 	cliParser.AddOption(cli.FlagOpt, "verbose", "v")
 	cliParser.AddOption(cli.FlagOpt, "dry-run", "d")
 	cliParser.AddOption(cli.FlagOpt, "help", "h")
+	cliParser.AddOption(cli.FlagOpt, "list", "l")
+	cliParser.AddOption(cli.FlagOpt, "sort", "s")
+	cliParser.AddOption(cli.FlagOpt, "json")
+	cliParser.AddOption(cli.FlagOpt, "cross-git")
 
 	pointerWriter := cli.NewPointerWriter()
 	pointerWriter.AddValue("temp-dir", &target.ScriptsDirectory)
@@ -31,6 +35,10 @@ func ParseOptions(args []string, target *hundfile.Options) ([]string, error) {
 	pointerWriter.AddFlag("verbose", &target.VerboseMode)
 	pointerWriter.AddFlag("dry-run", &target.DryRun)
 	pointerWriter.AddFlag("help", &target.ShowHelp)
+	pointerWriter.AddFlag("list", &target.ListTargets)
+	pointerWriter.AddFlag("sort", &target.SortTargets)
+	pointerWriter.AddFlag("json", &target.JsonOutput)
+	pointerWriter.AddFlag("cross-git", &target.CrossGit)
 
 	return cliParser.Parse(args, pointerWriter)
 }
@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"hund/logger"
+	"hund/util"
+	"os"
+	"path/filepath"
+)
+
+var HundfileNames = []string{"Hundfile", "hundfile", "Hundfile.hund"}
+
+func FindHundfile(startDir string, stopAtGit bool) (string, error) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		logger.Debugf("looking for Hundfile in \"%s\"", dir)
+		for _, name := range HundfileNames {
+			path := filepath.Join(dir, name)
+			info, err := os.Stat(path)
+			if err == nil && !info.IsDir() {
+				return path, nil
+			}
+		}
+
+		if stopAtGit && exists(filepath.Join(dir, ".git")) {
+			logger.Debugf("reached git repository root \"%s\"", dir)
+			break
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	return "", util.NewError("could not find Hundfile in \"%s\" or any of its parent directories", startDir)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,384 @@
+package parser
+
+import (
+	"hund/hundfile"
+	"hund/util"
+	"regexp"
+	"strings"
+)
+
+type TemplateNodeKind int
+
+const (
+	TextNode     TemplateNodeKind = iota
+	VariableNode TemplateNodeKind = iota
+	CallNode     TemplateNodeKind = iota
+	EmbedNode    TemplateNodeKind = iota
+	IfNode       TemplateNodeKind = iota
+	ForNode      TemplateNodeKind = iota
+)
+
+type TemplateNode struct {
+	Kind     TemplateNodeKind
+	Text     string
+	Variable RendererRepr
+	Args     []TemplateArg
+	Branches []TemplateBranch
+	Loop     TemplateLoop
+}
+
+type TemplateLoop struct {
+	Variable string
+	List     string
+	Nodes    []TemplateNode
+}
+
+type TemplateBranch struct {
+	Condition *Condition
+	Nodes     []TemplateNode
+}
+
+type TemplateArg struct {
+	Nodes  []TemplateNode
+	Quoted bool
+}
+
+const ESCAPE = "@"
+
+var loopExpression = regexp.MustCompile(`^(?P<variable>` + IDENTIFIER + `)[ \t]+in[ \t]+(?P<list>` + IDENTIFIER + `)$`)
+var templateMarkers = []string{"@{{", "@((", "@[["}
+var variableStartExtractor = regexp.MustCompile(`^` + variableNameExtractor.String())
+var callStartExtractor = regexp.MustCompile(`^` + targetCallExtractor.String())
+var embedStartExtractor = regexp.MustCompile(`^` + targetEmbedCallExtractor.String())
+
+func ParseTemplate(script string) ([]TemplateNode, error) {
+	nodes, _, err := parseTemplate(script)
+	return nodes, err
+}
+
+func parseTemplate(script string) ([]TemplateNode, int, error) {
+	lines := dedentBlocks(strings.SplitAfter(script, "\n"))
+	nodes, next, err := parseBlock(lines, 0)
+	if err != nil {
+		return nodes, next, err
+	}
+	if next < len(lines) {
+		name, _, _ := getBlockDirective(lines[next])
+		return nodes, next, util.NewError("unexpected @%s", name)
+	}
+	return nodes, next, nil
+}
+
+type blockIndent struct {
+	indent     string
+	nested     string
+	determined bool
+}
+
+func dedentBlocks(lines []string) []string {
+	result := []string{}
+	blocks := []*blockIndent{}
+
+	for _, line := range lines {
+		name, _, isDirective := getBlockDirective(line)
+		opening := name == "if" || name == "for"
+		depth := len(blocks)
+		if isDirective && !opening && depth > 0 {
+			depth -= 1
+		}
+
+		for _, block := range blocks[:depth] {
+			if !block.determined && strings.TrimSpace(line) != "" {
+				block.determined = true
+				whitespace := leadingWhitespace(line)
+				if strings.HasPrefix(whitespace, block.indent) && whitespace != block.indent {
+					block.nested = whitespace
+				}
+			}
+			if block.nested != "" && strings.HasPrefix(line, block.nested) {
+				line = block.indent + line[len(block.nested):]
+			}
+		}
+
+		if isDirective && opening {
+			blocks = append(blocks, &blockIndent{indent: leadingWhitespace(line)})
+		}
+		if isDirective && (name == "elif" || name == "else") && len(blocks) > 0 {
+			*blocks[len(blocks)-1] = blockIndent{indent: leadingWhitespace(line)}
+		}
+		if isDirective && name == "end" && len(blocks) > 0 {
+			blocks = blocks[:len(blocks)-1]
+		}
+		result = append(result, line)
+	}
+
+	return result
+}
+
+func leadingWhitespace(text string) string {
+	return text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+}
+
+func parseBlock(lines []string, start int) ([]TemplateNode, int, error) {
+	result := []TemplateNode{}
+
+	i := start
+	for i < len(lines) {
+		name, args, ok := getBlockDirective(lines[i])
+		if !ok {
+			result = append(result, parseInline(unescapeBlockDirective(lines[i]))...)
+			i += 1
+			continue
+		}
+		var parse func([]string, int, string) (TemplateNode, int, error)
+		switch name {
+		case "if":
+			parse = parseIf
+		case "for":
+			parse = parseFor
+		default:
+			return result, i, nil
+		}
+
+		node, next, err := parse(lines, i, args)
+		if err != nil {
+			return result, next, err
+		}
+		result = append(result, node)
+		i = next
+	}
+	return result, i, nil
+}
+
+func parseIf(lines []string, start int, args string) (TemplateNode, int, error) {
+	node := TemplateNode{Kind: IfNode}
+	name := "if"
+
+	i := start
+	for {
+		branch := TemplateBranch{}
+		if name == "else" && args != "" {
+			return node, i, util.NewError("unexpected arguments \"%s\" to @else", args)
+		}
+		if name != "else" {
+			condition, err := ParseCondition(args)
+			if err != nil {
+				return node, i, err
+			}
+			branch.Condition = &condition
+		}
+
+		nodes, next, err := parseBlock(lines, i+1)
+		if err != nil {
+			return node, next, err
+		}
+		branch.Nodes = nodes
+		node.Branches = append(node.Branches, branch)
+
+		if next >= len(lines) {
+			return node, start, util.NewError("missing @end for @if")
+		}
+
+		nextName, nextArgs, _ := getBlockDirective(lines[next])
+		if nextName == "end" && nextArgs != "" {
+			return node, next, util.NewError("unexpected arguments \"%s\" to @end", nextArgs)
+		}
+		if nextName == "end" {
+			return node, next + 1, nil
+		}
+		if name == "else" {
+			return node, next, util.NewError("unexpected @%s after @else", nextName)
+		}
+		name, args, i = nextName, nextArgs, next
+	}
+}
+
+func ParseLoop(text string) (string, string, error) {
+	match := loopExpression.FindStringSubmatch(strings.TrimSpace(text))
+	if match == nil {
+		return "", "", util.NewError("invalid loop \"%s\", expected \"<name> in <list>\"", text)
+	}
+	variable := match[loopExpression.SubexpIndex("variable")]
+	list := match[loopExpression.SubexpIndex("list")]
+	if variable == hundfile.INVOCATION_DIR {
+		return "", "", util.NewError("\"%s\" is a built-in variable", variable)
+	}
+	return variable, list, nil
+}
+
+func parseFor(lines []string, start int, args string) (TemplateNode, int, error) {
+	node := TemplateNode{Kind: ForNode}
+
+	variable, list, err := ParseLoop(args)
+	if err != nil {
+		return node, start, err
+	}
+
+	nodes, next, err := parseBlock(lines, start+1)
+	if err != nil {
+		return node, next, err
+	}
+	node.Loop = TemplateLoop{Variable: variable, List: list, Nodes: nodes}
+
+	if next >= len(lines) {
+		return node, start, util.NewError("missing @end for @for")
+	}
+
+	name, args, _ := getBlockDirective(lines[next])
+	if name != "end" {
+		return node, next, util.NewError("unexpected @%s in @for", name)
+	}
+	if args != "" {
+		return node, next, util.NewError("unexpected arguments \"%s\" to @end", args)
+	}
+	return node, next + 1, nil
+}
+
+func parseInline(script string) []TemplateNode {
+	result := []TemplateNode{}
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() > 0 {
+			result = append(result, TemplateNode{Kind: TextNode, Text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for len(script) > 0 {
+		index := strings.Index(script, "@")
+		if index < 0 {
+			text.WriteString(script)
+			break
+		}
+		text.WriteString(script[:index])
+		script = script[index:]
+
+		if marker, ok := escapedMarker(script); ok {
+			text.WriteString(marker)
+			script = script[len(ESCAPE)+len(marker):]
+			continue
+		}
+
+		if match := variableStartExtractor.FindStringSubmatch(script); match != nil {
+			flush()
+			result = append(result, TemplateNode{Kind: VariableNode, Variable: newRendererRepr(match)})
+			script = script[len(match[0]):]
+			continue
+		}
+
+		if match := callStartExtractor.FindStringSubmatch(script); match != nil {
+			flush()
+			name := match[callStartExtractor.SubexpIndex("name")]
+			result = append(result, TemplateNode{Kind: CallNode, Text: match[0], Args: parseTemplateArgs(name)})
+			script = script[len(match[0]):]
+			continue
+		}
+
+		if match := embedStartExtractor.FindStringSubmatch(script); match != nil {
+			flush()
+			name := match[embedStartExtractor.SubexpIndex("name")]
+			result = append(result, TemplateNode{Kind: EmbedNode, Text: match[0], Args: parseTemplateArgs(name)})
+			script = script[len(match[0]):]
+			continue
+		}
+
+		text.WriteString("@")
+		script = script[1:]
+	}
+	flush()
+
+	return result
+}
+
+func escapedMarker(text string) (string, bool) {
+	rest, ok := strings.CutPrefix(text, ESCAPE)
+	if !ok {
+		return "", false
+	}
+	for _, marker := range templateMarkers {
+		if strings.HasPrefix(rest, marker) {
+			return marker, true
+		}
+	}
+	return "", false
+}
+
+func maskEscapes(text string) string {
+	for _, marker := range templateMarkers {
+		mask := strings.Repeat(" ", len(ESCAPE)+len(marker))
+		text = strings.ReplaceAll(text, ESCAPE+marker, mask)
+	}
+	return text
+}
+
+func newRendererRepr(match []string) RendererRepr {
+	return RendererRepr{
+		Name:     match[variableNameExtractor.SubexpIndex("name")],
+		InScript: match[0],
+		Index:    match[variableNameExtractor.SubexpIndex("index")],
+		Filters:  match[variableNameExtractor.SubexpIndex("filters")],
+	}
+}
+
+type callWord struct {
+	text   string
+	quoted bool
+}
+
+func parseTemplateArgs(text string) []TemplateArg {
+	result := []TemplateArg{}
+	for _, word := range splitCallWords(text) {
+		result = append(result, TemplateArg{Nodes: parseInline(word.text), Quoted: word.quoted})
+	}
+	return result
+}
+
+func SplitCallArgs(text string) []string {
+	result := []string{}
+	for _, word := range splitCallWords(text) {
+		result = append(result, word.text)
+	}
+	return result
+}
+
+func splitCallWords(text string) []callWord {
+	result := []callWord{}
+	word := callWord{}
+	inWord, inQuotes := false, false
+
+	for len(text) > 0 {
+		if match := variableStartExtractor.FindString(text); match != "" {
+			word.text += match
+			inWord = true
+			text = text[len(match):]
+			continue
+		}
+
+		ch := text[:1]
+		text = text[1:]
+		switch {
+		case ch == "\\" && inQuotes && (strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "\\")):
+			word.text += text[:1]
+			text = text[1:]
+		case ch == "\"":
+			inQuotes = !inQuotes
+			word.quoted = true
+			inWord = true
+		case (ch == " " || ch == "\t") && !inQuotes:
+			if inWord {
+				result = append(result, word)
+			}
+			word = callWord{}
+			inWord = false
+		default:
+			word.text += ch
+			inWord = true
+		}
+	}
+	if inWord {
+		result = append(result, word)
+	}
+
+	return result
+}
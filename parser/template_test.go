@@ -0,0 +1,203 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	nodes, err := ParseTemplate("echo @{{ name | q }} @@{{name}} @(( build \"a b\" @{{files}} )) @[[ x ]]@")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	kinds := []TemplateNodeKind{TextNode, VariableNode, TextNode, CallNode, TextNode, EmbedNode, TextNode}
+	if len(nodes) != len(kinds) {
+		t.Fatalf("got %d nodes; want %d", len(nodes), len(kinds))
+	}
+	for i, node := range nodes {
+		if node.Kind != kinds[i] {
+			t.Errorf("node %d: got kind %d; want %d", i, node.Kind, kinds[i])
+		}
+	}
+
+	if nodes[1].Variable.Name != "name" || nodes[1].Variable.Filters != "| q " {
+		t.Errorf("got variable %v", nodes[1].Variable)
+	}
+	if nodes[2].Text != " @{{name}} " {
+		t.Errorf("got escaped text \"%s\"", nodes[2].Text)
+	}
+	if nodes[6].Text != "@" {
+		t.Errorf("got trailing text \"%s\"", nodes[6].Text)
+	}
+
+	args := nodes[3].Args
+	if len(args) != 3 || !args[1].Quoted || args[2].Nodes[0].Kind != VariableNode {
+		t.Errorf("got call args %v", args)
+	}
+}
+
+func TestQuotedFilterArgs(t *testing.T) {
+	testCases := []struct {
+		script  string
+		filters string
+	}{
+		{`echo @{{ x | default:"}" }}`, `| default:"}" `},
+		{`echo @{{ x | default:"a\"}}" | q }}`, `| default:"a\"}}" | q `},
+		{`echo @{{ x | replace:"}}":"" }}`, `| replace:"}}":"" `},
+	}
+
+	for _, tc := range testCases {
+		nodes, err := ParseTemplate(tc.script)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(nodes) != 2 || nodes[1].Kind != VariableNode {
+			t.Errorf("%s: got nodes %v", tc.script, nodes)
+			continue
+		}
+		if nodes[1].Variable.Filters != tc.filters {
+			t.Errorf("%s: got filters %q; want %q", tc.script, nodes[1].Variable.Filters, tc.filters)
+		}
+	}
+}
+
+func TestSplitCallArgs(t *testing.T) {
+	testCases := []struct {
+		text string
+		args []string
+	}{
+		{"build", []string{"build"}},
+		{"build  a\tb", []string{"build", "a", "b"}},
+		{"build \"a b\" \"\"", []string{"build", "a b", ""}},
+		{"build @{{ name | default:x }} x@{{ y }}", []string{"build", "@{{ name | default:x }}", "x@{{ y }}"}},
+		{`say "say \"hi\""`, []string{"say", `say "hi"`}},
+		{`say "a\\" b`, []string{"say", `a\`, "b"}},
+		{`say "a\b" c\"d"`, []string{"say", `a\b`, `c\d`}},
+	}
+
+	for _, tc := range testCases {
+		args := SplitCallArgs(tc.text)
+		if fmt.Sprintf("%q", args) != fmt.Sprintf("%q", tc.args) {
+			t.Errorf("got %q; want %q", args, tc.args)
+		}
+	}
+}
+
+func TestParseIf(t *testing.T) {
+	script := "@if a\n    echo a\n@elif b == \"x y\"\n  echo b\n@else\n    @if !c\n        echo c\n    @end\n@end\necho done"
+	nodes, err := ParseTemplate(script)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(nodes) != 2 || nodes[0].Kind != IfNode || nodes[1].Text != "echo done" {
+		t.Fatalf("got nodes %v", nodes)
+	}
+
+	branches := nodes[0].Branches
+	if len(branches) != 3 {
+		t.Fatalf("got %d branches; want 3", len(branches))
+	}
+	if branches[0].Nodes[0].Text != "echo a\n" || branches[1].Nodes[0].Text != "echo b\n" {
+		t.Errorf("got branch texts \"%s\" and \"%s\"", branches[0].Nodes[0].Text, branches[1].Nodes[0].Text)
+	}
+	if *branches[1].Condition != (Condition{Name: "b", Operator: "==", Value: "x y"}) {
+		t.Errorf("got condition %v", *branches[1].Condition)
+	}
+	if branches[2].Condition != nil {
+		t.Errorf("got condition %v for @else", *branches[2].Condition)
+	}
+
+	nested := branches[2].Nodes[0]
+	if nested.Kind != IfNode || !nested.Branches[0].Condition.Negated || nested.Branches[0].Nodes[0].Text != "echo c\n" {
+		t.Errorf("got nested node %v", nested)
+	}
+}
+
+func TestInvalidIf(t *testing.T) {
+	scripts := []string{
+		"@if a\necho",
+		"@end",
+		"@if a\n@else\n@elif b\n@end",
+		"@if a ==\n@end",
+		"@if !a == b\n@end",
+	}
+
+	for _, script := range scripts {
+		_, err := ParseTemplate(script)
+		if err == nil {
+			t.Errorf("%q: expected error", script)
+		}
+	}
+}
+
+func TestDirectiveLookalikes(t *testing.T) {
+	script := "@end-marker\n@if-up\n    @@if x\n@@end\n@@@for"
+	nodes, err := ParseTemplate(script)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	text := ""
+	for _, node := range nodes {
+		if node.Kind != TextNode {
+			t.Errorf("got node %v", node)
+		}
+		text += node.Text
+	}
+	want := "@end-marker\n@if-up\n    @if x\n@end\n@@@for"
+	if text != want {
+		t.Errorf("got %q; want %q", text, want)
+	}
+}
+
+func TestConditionEval(t *testing.T) {
+	testCases := []struct {
+		text   string
+		value  string
+		result bool
+	}{
+		{"flag", "x", true},
+		{"flag", "", false},
+		{"count", "0", false},
+		{"!flag", "", true},
+		{"mode == release", "release", true},
+		{"mode != \"release\"", "release", false},
+	}
+
+	for _, tc := range testCases {
+		condition, err := ParseCondition(tc.text)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if condition.Eval(tc.value) != tc.result {
+			t.Errorf("%s with \"%s\": got %v", tc.text, tc.value, !tc.result)
+		}
+	}
+}
+
+func TestParseFor(t *testing.T) {
+	nodes, err := ParseTemplate("@for f in files\n    @(( build @{{f}} ))\n@end\n")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(nodes) != 1 || nodes[0].Kind != ForNode {
+		t.Fatalf("got nodes %v", nodes)
+	}
+	loop := nodes[0].Loop
+	if loop.Variable != "f" || loop.List != "files" {
+		t.Errorf("got loop %s in %s", loop.Variable, loop.List)
+	}
+	if len(loop.Nodes) != 2 || loop.Nodes[0].Kind != CallNode || loop.Nodes[1].Text != "\n" {
+		t.Errorf("got loop nodes %v", loop.Nodes)
+	}
+
+	for _, script := range []string{"@for f in files\necho", "@for f\n@end", "@for f in files\n@else\n@end"} {
+		_, err := ParseTemplate(script)
+		if err == nil {
+			t.Errorf("%q: expected error", script)
+		}
+	}
+}
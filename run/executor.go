@@ -4,29 +4,25 @@ import (
 	"fmt"
 	"hund/hundfile"
 	"hund/logger"
-	"hund/util"
 	"os"
 	"os/exec"
 	"os/signal"
-	"strings"
+	"path/filepath"
+	"slices"
 	"syscall"
 )
 
 type Executor struct {
-	shell     string
-	shellArgs []string
-	tempDir   string
+	tempDir string
 }
 
-func NewExecutor(options hundfile.Options, hundfile hundfile.Hundfile) Executor {
+func NewExecutor(options hundfile.Options) Executor {
 	return Executor{
-		shell:     hundfile.Shell,
-		shellArgs: hundfile.ShellArgs,
-		tempDir:   options.ScriptsDirectory,
+		tempDir: options.ScriptsDirectory,
 	}
 }
 
-func (e Executor) Exec(script string) (int, error) {
+func (e Executor) Exec(script Script) (int, error) {
 	f, err := os.CreateTemp(e.tempDir, "hund-run")
 	if err != nil {
 		return 0, err
@@ -35,18 +31,26 @@ func (e Executor) Exec(script string) (int, error) {
 
 	logger.Debugf("created script file %s\n", f.Name())
 
-	_, err = f.WriteString(script)
+	_, err = f.WriteString(script.Text)
 	if err != nil {
 		return 0, err
 	}
 
 	defer os.Remove(f.Name())
 
-	args := append(e.shellArgs, f.Name())
-	cmd := exec.Command(e.shell, args...)
+	target := script.Target
+	scriptPath, err := filepath.Abs(f.Name())
+	if err != nil {
+		return 0, err
+	}
+	args := append(slices.Clone(target.ShellArgs), scriptPath)
+	cmd := exec.Command(target.Shell, args...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	cmd.Env = target.Environ()
+	cmd.Dir = target.WorkDir()
+	logger.Debugf("running \"%s\" with %s %v in \"%s\"", target.Name, target.Shell, args, cmd.Dir)
 
 	logger.Debugln("starting target")
 	err = cmd.Start()
@@ -70,14 +74,5 @@ func (e Executor) Exec(script string) (int, error) {
 }
 
 func (self Executor) String() string {
-	args := []string{}
-	for _, arg := range self.shellArgs {
-		args = append(args, util.Quote(arg))
-	}
-	shellArgs := strings.Join(args, ", ")
-
-	return fmt.Sprintf(
-		"Shell: \"%s\"\nShellArgs: [%s]\nTempDir: \"%s\"",
-		self.shell, shellArgs, self.tempDir,
-	)
+	return fmt.Sprintf("TempDir: \"%s\"", self.tempDir)
 }
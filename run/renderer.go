@@ -7,24 +7,38 @@ import (
 	"hund/logger"
 	"hund/parser"
 	"hund/util"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
 )
 
+type Script struct {
+	Target hundfile.Target
+	Text   string
+}
+
 type Renderer struct {
-	hundfile       hundfile.Hundfile
-	visitedTargets []string
+	hundfile         hundfile.Hundfile
+	visitedTargets   []string
+	scheduledTargets map[string]bool
+	scripts          []Script
+	scriptShell      string
 }
 
 func NewRenderer(hundfile hundfile.Hundfile) Renderer {
 	return Renderer{
-		hundfile:       hundfile,
-		visitedTargets: []string{},
+		hundfile:         hundfile,
+		visitedTargets:   []string{},
+		scheduledTargets: make(map[string]bool),
+		scripts:          []Script{},
 	}
 }
 
-func (self *Renderer) Render(args []string) (string, error) {
+func (self *Renderer) Render(args []string) ([]Script, error) {
 	if len(args) == 0 {
-		return "", util.NewError("missing target name")
+		return nil, util.NewError("missing target name")
 	}
 
 	targetName := args[0]
@@ -32,85 +46,279 @@ func (self *Renderer) Render(args []string) (string, error) {
 
 	logger.Debugf("rendering \"%s\"", targetName)
 
-	script, err := self.innerRender(targetName, args)
+	err := self.schedule("", targetName, args)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return script, nil
+	return self.scripts, nil
+}
+
+func (self *Renderer) schedule(namespace string, targetName string, args []string) error {
+	target, err := self.hundfile.ResolveTarget(targetName, namespace)
+	if err != nil {
+		return err
+	}
+
+	shell := self.scriptShell
+	self.scriptShell = target.Shell
+	script, err := self.innerRender(namespace, targetName, args)
+	self.scriptShell = shell
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("scheduling \"%s\" as script %d", target.Name, len(self.scripts)+1)
+	self.scripts = append(self.scripts, Script{Target: target, Text: script})
+	self.scheduledTargets[target.Name] = true
+	return nil
 }
 
-func (self *Renderer) innerRender(targetName string, args []string) (string, error) {
+func (self *Renderer) renderPrerequisites(target hundfile.Target) error {
+	for _, name := range target.Prerequisites {
+		prerequisite, err := self.hundfile.GetTarget(name)
+		if err != nil {
+			return err
+		}
+
+		if self.scheduledTargets[prerequisite.Name] {
+			logger.Debugf("prerequisite \"%s\" already scheduled", prerequisite.Name)
+			continue
+		}
+
+		err = self.schedule("", name, []string{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *Renderer) innerRender(namespace string, targetName string, args []string) (string, error) {
 	logger.Debugf("rendering script \"%s\"", targetName)
 	script := ""
-	if self.visited(targetName) {
+	target, err := self.hundfile.ResolveTarget(targetName, namespace)
+	if err != nil {
+		return script, err
+	}
+
+	if self.visited(target.Name) {
 		circle := strings.Join(self.visitedTargets, " -> ")
-		circle += fmt.Sprintf(" -> %s", targetName)
+		circle += fmt.Sprintf(" -> %s", target.Name)
 		return script, util.NewError("detected circular dependency %s", circle)
 	}
-	self.visitedTargets = append(self.visitedTargets, targetName)
+	isCommand := len(self.visitedTargets) == 0
+	self.visitedTargets = append(self.visitedTargets, target.Name)
 
-	target, err := self.hundfile.GetTarget(targetName)
+	logger.Debugf("parsing %d arguments", len(args))
+	variables := make(map[string]string)
+	invocationDir, err := os.Getwd()
 	if err != nil {
 		return script, err
 	}
-
-	logger.Debugf("parsing %d arguments", len(args))
-	variables := make(map[string]string)
-	writer := cli.NewMapWriter(variables, self.hundfile.FlagValue)
-	leftoverArgs, err := target.Parser.Parse(args, writer)
+	variables[hundfile.INVOCATION_DIR] = invocationDir
+	for name, value := range target.Variables {
+		if !target.Parser.Contains(name) {
+			variables[name] = value
+		}
+	}
+	maps.Copy(variables, target.Parser.Defaults(target.FlagValue))
+	writer := cli.NewMapWriter(variables, target.FlagValue)
+	parse := target.Parser.Parse
+	if isCommand {
+		parse = target.Parser.ParseCommand
+	}
+	leftoverArgs, err := parse(args, writer)
 	if err != nil {
 		return script, err
 	}
 	if len(leftoverArgs) > 0 {
 		return script, util.NewError("invalid arguments %v", leftoverArgs)
 	}
+
+	logger.Debugf("rendering %d prerequisites", len(target.Prerequisites))
+	err = self.renderPrerequisites(target)
+	if err != nil {
+		return script, err
+	}
 	script = target.Script
 
-	logger.Debugf("rendering variables")
-	variableUses := parser.GetVariables(script)
-	for _, variableUse := range variableUses {
-		name := variableUse.Name
-		value := variables[name]
-		script = strings.ReplaceAll(script, variableUse.InScript, value)
+	logger.Debugf("rendering template")
+	nodes, err := parser.ParseTemplate(script)
+	if err != nil {
+		return script, err
+	}
+	script, err = self.renderTemplate(target, nodes, variables, writer.Lists())
+	if err != nil {
+		return script, err
+	}
+	if !strings.HasSuffix(target.Script, "\n") {
+		script = strings.TrimSuffix(script, "\n")
+	}
+
+	self.visitedTargets = self.visitedTargets[:len(self.visitedTargets)-1]
+	return script, nil
+}
+
+func (self *Renderer) renderTemplate(target hundfile.Target, nodes []parser.TemplateNode, variables map[string]string, lists map[string][]string) (string, error) {
+	var builder strings.Builder
+	for _, node := range nodes {
+		switch node.Kind {
+		case parser.TextNode:
+			builder.WriteString(node.Text)
+		case parser.VariableNode:
+			value, err := self.renderVariable(target, node.Variable, variables, lists, target.AutoQuote)
+			if err != nil {
+				return "", err
+			}
+			builder.WriteString(value)
+		case parser.CallNode, parser.EmbedNode:
+			result, err := self.renderCall(target, node, variables, lists)
+			if err != nil {
+				return "", err
+			}
+			builder.WriteString(result)
+		case parser.IfNode:
+			result, err := self.renderIf(target, node, variables, lists)
+			if err != nil {
+				return "", err
+			}
+			builder.WriteString(result)
+		case parser.ForNode:
+			result, err := self.renderFor(target, node.Loop, variables, lists)
+			if err != nil {
+				return "", err
+			}
+			builder.WriteString(result)
+		}
 	}
+	return builder.String(), nil
+}
 
-	logger.Debugf("rendering calls")
-	callUses := parser.GetCalls(script)
-	for _, callUse := range callUses {
-		args := util.StringToArgs(callUse.Name)
-		if len(args) < 1 {
-			return script, util.NewError("invalid call %s", callUse.InScript)
+func (self *Renderer) renderIf(target hundfile.Target, node parser.TemplateNode, variables map[string]string, lists map[string][]string) (string, error) {
+	for _, branch := range node.Branches {
+		if branch.Condition != nil {
+			value, _ := self.lookupValue(target, branch.Condition.Name, variables)
+			if !branch.Condition.Eval(value) {
+				continue
+			}
 		}
-		callName := args[0]
-		callArgs := args[1:]
-		callResult, err := self.innerRender(callName, callArgs)
+		return self.renderTemplate(target, branch.Nodes, variables, lists)
+	}
+	return "", nil
+}
+
+func (self *Renderer) renderFor(target hundfile.Target, loop parser.TemplateLoop, variables map[string]string, lists map[string][]string) (string, error) {
+	var builder strings.Builder
+	for _, value := range lists[loop.List] {
+		loopVariables := maps.Clone(variables)
+		loopVariables[loop.Variable] = value
+		result, err := self.renderTemplate(target, loop.Nodes, loopVariables, lists)
 		if err != nil {
-			return script, err
+			return "", err
+		}
+		builder.WriteString(result)
+	}
+	return builder.String(), nil
+}
+
+func (self *Renderer) renderCall(target hundfile.Target, node parser.TemplateNode, variables map[string]string, lists map[string][]string) (string, error) {
+	args := []string{}
+	for _, arg := range node.Args {
+		values, err := self.renderCallArg(target, arg, variables, lists)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, values...)
+	}
+	if len(args) < 1 {
+		return "", util.NewError("invalid call %s", node.Text)
+	}
+
+	result, err := self.innerRender(target.Namespace, args[0], args[1:])
+	if err != nil {
+		return "", err
+	}
+	if node.Kind == parser.EmbedNode {
+		result = strings.ReplaceAll(result, "\n", target.EmbedSep)
+	}
+	return result, nil
+}
+
+func (self *Renderer) renderCallArg(target hundfile.Target, arg parser.TemplateArg, variables map[string]string, lists map[string][]string) ([]string, error) {
+	if !arg.Quoted && len(arg.Nodes) == 1 && arg.Nodes[0].Kind == parser.VariableNode {
+		variable := arg.Nodes[0].Variable
+		if target.Parser.IsList(variable.Name) && variable.Index == "" && variable.Filters == "" {
+			return slices.Clone(lists[variable.Name]), nil
 		}
-		script = strings.ReplaceAll(script, callUse.InScript, callResult)
 	}
 
-	logger.Debugf("rendering embeds")
-	embedUses := parser.GetEmbeds(script)
-	for _, embedUse := range embedUses {
-		args := util.StringToArgs(embedUse.Name)
-		if len(args) < 1 {
-			return script, util.NewError("invalid embed %s", embedUse.InScript)
+	value := ""
+	for _, node := range arg.Nodes {
+		if node.Kind != parser.VariableNode {
+			value += node.Text
+			continue
 		}
-		embedName := args[0]
-		embedArgs := args[1:]
-		embedResult, err := self.innerRender(embedName, embedArgs)
+		rendered, err := self.renderVariable(target, node.Variable, variables, lists, false)
 		if err != nil {
-			return script, err
+			return nil, err
 		}
-		embedSep := self.hundfile.EmbedSep
-		embedResult = strings.ReplaceAll(embedResult, "\n", embedSep)
-		script = strings.ReplaceAll(script, embedUse.InScript, embedResult)
+		value += rendered
 	}
 
-	self.visitedTargets = self.visitedTargets[:len(self.visitedTargets)-1]
-	return script, nil
+	if value == "" && !arg.Quoted {
+		return []string{}, nil
+	}
+	return []string{value}, nil
+}
+
+func (self *Renderer) renderVariable(target hundfile.Target, variableUse parser.RendererRepr, variables map[string]string, lists map[string][]string, autoQuote bool) (string, error) {
+	filters, err := parser.ParseFilters(variableUse.Filters)
+	if err != nil {
+		return "", err
+	}
+	autoQuote = autoQuote && !parser.HasFilter(filters, "q") && !parser.HasFilter(filters, "raw")
+
+	values := []string{variables[variableUse.Name]}
+	if target.Parser.IsList(variableUse.Name) {
+		values = lists[variableUse.Name]
+	}
+	if variableUse.Index != "" {
+		values = []string{""}
+		index, err := strconv.Atoi(variableUse.Index)
+		if err != nil {
+			return "", err
+		}
+		list := lists[variableUse.Name]
+		if index < len(list) {
+			values = []string{list[index]}
+		} else if !parser.HasFilter(filters, "default") {
+			return "", util.NewError("list \"%s\" has no value at index %d", variableUse.Name, index)
+		}
+	}
+
+	envName, isEnv := strings.CutPrefix(variableUse.Name, parser.ENV_PREFIX)
+	if isEnv {
+		envValue, isSet := self.lookupValue(target, variableUse.Name, variables)
+		if !isSet && !parser.HasFilter(filters, "default") {
+			return "", util.NewError("environment variable \"%s\" is not set", envName)
+		}
+		values = []string{envValue}
+	}
+
+	if autoQuote {
+		return parser.ApplyAutoQuote(values, filters, self.scriptShell), nil
+	}
+	return parser.ApplyFilters(values, filters, self.scriptShell), nil
+}
+
+func (self *Renderer) lookupValue(target hundfile.Target, name string, variables map[string]string) (string, bool) {
+	envName, isEnv := strings.CutPrefix(name, parser.ENV_PREFIX)
+	if isEnv {
+		return target.LookupEnv(envName)
+	}
+	value, ok := variables[name]
+	return value, ok
 }
 
 func (self *Renderer) visited(targetName string) bool {
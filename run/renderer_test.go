@@ -0,0 +1,194 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"hund/cli"
+	"hund/hundfile"
+	"hund/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestHundfile(t *testing.T, files map[string]string) hundfile.Hundfile {
+	dir := t.TempDir()
+	for name, text := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	filename := filepath.Join(dir, "Hundfile")
+
+	lines, err := parser.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	result, err := parser.NewHundfileParser(filename).Parse(lines)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return result
+}
+
+func TestHelpRequest(t *testing.T) {
+	hundfile := newTestHundfile(t, map[string]string{
+		"Hundfile": "show(msg):\n    @(( echoit @{{msg}} ))\n\nechoit(m?):\n    echo @{{m}}\n",
+	})
+
+	renderer := NewRenderer(hundfile)
+	_, err := renderer.Render([]string{"show", "--help"})
+	if !errors.Is(err, cli.ErrHelpRequested) {
+		t.Errorf("got %v; want help request", err)
+	}
+
+	renderer = NewRenderer(hundfile)
+	_, err = renderer.Render([]string{"show", "--", "--help"})
+	if err == nil || errors.Is(err, cli.ErrHelpRequested) {
+		t.Errorf("got %v; want invalid option error", err)
+	}
+}
+
+func TestIncludedQuoting(t *testing.T) {
+	hundfile := newTestHundfile(t, map[string]string{
+		"Hundfile": "@include(lib)\n\nfish(msg):\n    @shell(fish)\n    @(( say @{{msg}} ))\n",
+		"lib":      "@quote(auto)\n\nsay(msg):\n    echo @{{msg}}\n",
+	})
+
+	testCases := []struct {
+		args   []string
+		script string
+	}{
+		{[]string{"say", "it's"}, `echo 'it'\''s'`},
+		{[]string{"fish", "it's"}, `echo 'it\'s'`},
+	}
+
+	for _, tc := range testCases {
+		renderer := NewRenderer(hundfile)
+		scripts, err := renderer.Render(tc.args)
+		if err != nil {
+			t.Errorf("%v: unexpected error \"%s\"", tc.args, err)
+			continue
+		}
+		if scripts[0].Text != tc.script {
+			t.Errorf("%v: got %s; want %s", tc.args, scripts[0].Text, tc.script)
+		}
+	}
+}
+
+func TestIncludedGlobals(t *testing.T) {
+	hundfile := newTestHundfile(t, map[string]string{
+		"Hundfile": "@include(lib)\n\nboth: f=flag\n    echo @{{f}} @[[ libt --f ]]\n",
+		"lib":      "@flagValue(yes)\n@embedSep( && )\n\nlibt: f=flag\n    echo @{{f}}\n    echo done\n\nembed:\n    @[[ libt --f ]]\n",
+	})
+
+	testCases := []struct {
+		args   []string
+		script string
+	}{
+		{[]string{"libt", "--f"}, "echo yes\necho done"},
+		{[]string{"embed"}, "echo yes && echo done"},
+		{[]string{"both", "--f"}, "echo x echo yes;echo done"},
+	}
+
+	for _, tc := range testCases {
+		renderer := NewRenderer(hundfile)
+		scripts, err := renderer.Render(tc.args)
+		if err != nil {
+			t.Errorf("%v: unexpected error \"%s\"", tc.args, err)
+			continue
+		}
+		if scripts[0].Text != tc.script {
+			t.Errorf("%v: got %q; want %q", tc.args, scripts[0].Text, tc.script)
+		}
+	}
+}
+
+func TestListIndex(t *testing.T) {
+	hundfile := newTestHundfile(t, map[string]string{
+		"Hundfile": "first: tag|t=list\n    echo @{{tag[0] | default:none}}\n\nsecond: tag|t=list\n    echo @{{tag[1]}}\n",
+	})
+
+	testCases := []struct {
+		args   []string
+		script string
+	}{
+		{[]string{"first"}, "echo none"},
+		{[]string{"first", "-t", "a"}, "echo a"},
+		{[]string{"second", "-t", "a", "-t", "b"}, "echo b"},
+		{[]string{"second", "-t", "a"}, ""},
+	}
+
+	for _, tc := range testCases {
+		renderer := NewRenderer(hundfile)
+		scripts, err := renderer.Render(tc.args)
+		if tc.script == "" {
+			if err == nil {
+				t.Errorf("%v: expected error", tc.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error \"%s\"", tc.args, err)
+			continue
+		}
+		if scripts[0].Text != tc.script {
+			t.Errorf("%v: got %s; want %s", tc.args, scripts[0].Text, tc.script)
+		}
+	}
+}
+
+func TestNamespacedPrerequisites(t *testing.T) {
+	hundfile := newTestHundfile(t, map[string]string{
+		"Hundfile": "@include(db, as=db)\n\nsetup:\n    echo root setup\n",
+		"db":       "setup:\n    echo db setup\n\nmigrate: <- setup\n    echo migrate\n",
+	})
+
+	target, err := hundfile.GetTarget("db:migrate")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(target.Prerequisites) != 1 || target.Prerequisites[0] != "db:setup" {
+		t.Errorf("got prerequisites %v; want [db:setup]", target.Prerequisites)
+	}
+
+	renderer := NewRenderer(hundfile)
+	scripts, err := renderer.Render([]string{"db:migrate"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	names := []string{}
+	for _, script := range scripts {
+		names = append(names, script.Target.Name)
+	}
+	if fmt.Sprint(names) != "[db:setup db:migrate]" {
+		t.Errorf("got scripts %v; want [db:setup db:migrate]", names)
+	}
+}
+
+func TestPrerequisites(t *testing.T) {
+	hundfile := newTestHundfile(t, map[string]string{
+		"Hundfile": "a: <- b c\n    echo a\n\nb: <- c\n    echo b\n\nc:\n    echo c\n\nx: <- y\n    echo x\n\ny: <- x\n    echo y\n",
+	})
+
+	renderer := NewRenderer(hundfile)
+	scripts, err := renderer.Render([]string{"a"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	names := []string{}
+	for _, script := range scripts {
+		names = append(names, script.Target.Name)
+	}
+	if fmt.Sprint(names) != "[c b a]" {
+		t.Errorf("got scripts %v; want [c b a]", names)
+	}
+
+	renderer = NewRenderer(hundfile)
+	_, err = renderer.Render([]string{"x"})
+	if err == nil || !strings.Contains(err.Error(), "circular dependency x -> y -> x") {
+		t.Errorf("got %v; want circular dependency error", err)
+	}
+}